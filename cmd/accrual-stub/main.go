@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/accrualstub"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Panicln("CAN'T RUN ACCRUAL STUB:", err)
+	}
+}
+
+func run() error {
+	var (
+		adr  string
+		conf accrualstub.Config
+	)
+
+	flag.StringVar(&adr, "a", "localhost:8081", "host where accrual stub is run")
+	flag.IntVar(&conf.RateLimit, "rpm", 0, "requests per minute limit, 0 is unlimited")
+	flag.DurationVar(&conf.RetryAfter, "retry-after", 0, "Retry-After value for 429, end of minute window by default")
+	flag.DurationVar(&conf.DelayMin, "delay-min", 0, "minimal response delay")
+	flag.DurationVar(&conf.DelayMax, "delay-max", 0, "maximal response delay")
+	flag.Parse()
+
+	if env := os.Getenv("RUN_ADDRESS"); env != "" {
+		adr = env
+	}
+
+	logger := logger.NewLg()
+
+	stub := accrualstub.NewStub(logger, conf)
+
+	server := &http.Server{
+		Addr:        adr,
+		Handler:     accrualstub.NewRouter(stub),
+		ReadTimeout: 30 * time.Second,
+	}
+
+	logger.Infoln("ACCRUAL STUB START ON", adr)
+
+	if err := server.ListenAndServe(); err != nil {
+		return fmt.Errorf("CAN'T EXECUTE SERVER [%w]", err)
+	}
+	return nil
+}
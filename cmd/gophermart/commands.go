@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/conf"
+	"github.com/DmitryM7/yapr56.git/internal/controller"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/service"
+)
+
+const (
+	cmdServe = "serve"
+
+	usage = `usage: gophermart [command] [flags] [args]
+
+commands:
+  serve                     run HTTP server and accrual workers (default)
+  migrate up                apply pending migrations
+  migrate down              roll back the last applied migration
+  migrate status            list migrations and whether they are applied
+  migrate redo              roll back and reapply the last migration
+  user create LOGIN         create user, password is read from stdin
+  user block LOGIN          block user and revoke their refresh tokens
+  ledger recalc [DATE]      refix balances of all accounts on DATE (YYYY-MM-DD, today by default)
+
+flags are shared by all commands, see gophermart serve -h
+`
+)
+
+var (
+	ErrUnknownCommand = errors.New("UNKNOWN COMMAND")
+	ErrNoDatabase     = errors.New("DATABASE URI IS REQUIRED")
+	ErrBadArgs        = errors.New("BAD COMMAND ARGUMENTS")
+
+	// commands — подкоманды и их действия. Без подкоманды запускается serve.
+	commands = map[string][]string{
+		cmdServe:  nil,
+		"migrate": {"up", "down", "status", "redo"},
+		"user":    {"create", "block"},
+		"ledger":  {"recalc"},
+	}
+)
+
+// splitCommand отделяет подкоманду с действием («migrate up») от флагов. Аргументы,
+// начинающиеся с флага, относятся к serve, чтобы старый запуск без подкоманды работал.
+func splitCommand(args []string) (string, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return cmdServe, args, nil
+	}
+
+	actions, ok := commands[args[0]]
+
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+	}
+
+	if actions == nil {
+		return args[0], args[1:], nil
+	}
+
+	if len(args) < 2 || !slices.Contains(actions, args[1]) {
+		return "", nil, fmt.Errorf("%w: %s needs one of %s", ErrUnknownCommand, args[0], strings.Join(actions, ", "))
+	}
+
+	return args[0] + " " + args[1], args[2:], nil
+}
+
+// admin выполняет административную подкоманду над PostgreSQL и выводит результат в stdout.
+func admin(ctx context.Context, log logger.Lg, config conf.Config, cmd string) error {
+	if config.DB.URI == "" {
+		return fmt.Errorf("%w FOR %s", ErrNoDatabase, strings.ToUpper(cmd))
+	}
+
+	db, err := service.NewStorageService(log, config.DB.URI, poolConf(config))
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Warnln(err)
+		}
+	}()
+
+	switch cmd {
+	case "migrate up":
+		return migrateUp(ctx, &db, os.Stdout)
+	case "migrate down":
+		return migrateDown(ctx, &db, os.Stdout)
+	case "migrate status":
+		return migrateStatus(ctx, &db, os.Stdout)
+	case "migrate redo":
+		return migrateRedo(ctx, &db, os.Stdout)
+	case "user create":
+		return userCreate(ctx, &db, config.Args, os.Stdin, os.Stdout)
+	case "user block":
+		return userBlock(ctx, &db, config.Args, os.Stdout)
+	case "ledger recalc":
+		return ledgerRecalc(ctx, &db, config.Args, os.Stdout)
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownCommand, cmd)
+}
+
+func migrateUp(ctx context.Context, db *service.StorageService, out io.Writer) error {
+	applied, err := db.MigrateUp(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Fprintln(out, "no pending migrations")
+		return nil
+	}
+
+	for _, v := range applied {
+		fmt.Fprintln(out, "applied", v)
+	}
+
+	return nil
+}
+
+func migrateDown(ctx context.Context, db *service.StorageService, out io.Writer) error {
+	version, err := db.MigrateDown(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "rolled back", version)
+
+	return nil
+}
+
+func migrateRedo(ctx context.Context, db *service.StorageService, out io.Writer) error {
+	version, err := db.MigrateRedo(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "reapplied", version)
+
+	return nil
+}
+
+func migrateStatus(ctx context.Context, db *service.StorageService, out io.Writer) error {
+	status, err := db.MigrationStatus(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tNAME")
+
+	for _, st := range status {
+		state, at := "pending", "-"
+
+		if st.Applied {
+			state, at = "applied", st.AppliedAt.Format(time.DateTime)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", st.Version, state, at, st.Name)
+	}
+
+	return w.Flush()
+}
+
+// userCreate создаёт клиента с паролем из первой строки in, чтобы пароль
+// не попадал в историю команд и список процессов.
+func userCreate(ctx context.Context, db *service.StorageService, args []string, in io.Reader, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: user create LOGIN", ErrBadArgs)
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Scan()
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("CAN'T READ PASSWORD [%w]", err)
+	}
+
+	req := controller.UserRegisterRequest{Login: args[0], Password: strings.TrimRight(scanner.Text(), "\r")}
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	p, err := db.CreatePeson(ctx, models.Person{Login: req.Login, Pass: req.Password})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "created user", p.Login, "id", p.ID)
+
+	return nil
+}
+
+func userBlock(ctx context.Context, db *service.StorageService, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: user block LOGIN", ErrBadArgs)
+	}
+
+	p, err := db.BlockPerson(ctx, args[0])
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "blocked user", p.Login, "id", p.ID)
+
+	return nil
+}
+
+func ledgerRecalc(ctx context.Context, db *service.StorageService, args []string, out io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%w: ledger recalc [DATE]", ErrBadArgs)
+	}
+
+	opdate := time.Now()
+
+	if len(args) == 1 {
+		d, err := time.Parse(time.DateOnly, args[0])
+
+		if err != nil {
+			return fmt.Errorf("%w: DATE MUST BE YYYY-MM-DD [%w]", ErrBadArgs, err)
+		}
+
+		opdate = d
+	}
+
+	fixes, err := db.RecalcBalances(ctx, opdate)
+
+	if err != nil && len(fixes) == 0 {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCT\tPERSON\tBEFORE\tAFTER\t")
+
+	drift := 0
+
+	for _, f := range fixes {
+		mark := ""
+
+		if f.Before != f.After {
+			mark = "DRIFT"
+			drift++
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", f.Acct, f.Person, f.Before, f.After, mark)
+	}
+
+	if ferr := w.Flush(); ferr != nil && err == nil {
+		err = ferr
+	}
+
+	fmt.Fprintf(out, "recalculated %d accounts on %s, %d with drift\n", len(fixes), opdate.Format(time.DateOnly), drift)
+
+	return err
+}
@@ -1,52 +1,370 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
 	"github.com/DmitryM7/yapr56.git/internal/conf"
 	"github.com/DmitryM7/yapr56.git/internal/controller"
+	"github.com/DmitryM7/yapr56.git/internal/lifecycle"
 	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/metrics"
 	"github.com/DmitryM7/yapr56.git/internal/sec"
 	"github.com/DmitryM7/yapr56.git/internal/service"
+	"github.com/DmitryM7/yapr56.git/internal/tracing"
 )
 
+// backend — хранилище, общее для обработчиков, воркеров, проверок готовности и аудита.
+type backend interface {
+	controller.IStorage
+	controller.IQueueStorage
+	controller.IHealthStorage
+	controller.IAuditStorage
+	CountOrdersByStatus(ctx context.Context) (map[string]int, error)
+	Close() error
+}
+
 func main() {
 	if err := run(); err != nil {
-		log.Panicln("CAN'T RUN MAIN PROCEDURE:", err)
+		fmt.Fprintln(os.Stderr, "gophermart:", err)
+
+		if errors.Is(err, ErrUnknownCommand) || errors.Is(err, ErrBadArgs) {
+			fmt.Fprint(os.Stderr, usage)
+		}
+
+		os.Exit(1)
 	}
 }
 
 func run() error {
-	config := conf.NewConf()
+	cmd, args, err := splitCommand(os.Args[1:])
+
+	if err != nil {
+		return err
+	}
+
+	scope := conf.ScopeAdmin
+
+	if cmd == cmdServe {
+		scope = conf.ScopeServe
+	}
+
+	name := os.Args[0] + " " + cmd
+	config, err := conf.Load(name, scope, args, os.Getenv)
+
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logger, err := logger.New(logger.Conf{
+		Level:            config.Log.Level,
+		Format:           config.Log.Format,
+		SampleInitial:    config.Log.Sampling,
+		SampleThereafter: config.Log.Sampling,
+	})
+
+	if err != nil {
+		return fmt.Errorf("CAN'T INIT LOGGER [%w]", err)
+	}
+
+	for _, w := range config.Warnings {
+		logger.Warnln("CONFIG:", w)
+	}
+
+	if cmd == cmdServe {
+		return serve(logger, config, func() (conf.Config, error) {
+			return conf.Load(name, scope, args, os.Getenv)
+		})
+	}
+
+	defer func() {
+		_ = logger.Flush()
+	}()
+
+	return admin(context.Background(), logger, config, cmd)
+}
 
-	logger := logger.NewLg()
+// serve запускает HTTP-сервер и воркеры начислений; load перечитывает конфигурацию по SIGHUP.
+func serve(logger logger.Lg, config conf.Config, load func() (conf.Config, error)) error {
+	logger.Infow("READY...",
+		"config_file", config.File,
+		"address", config.HTTP.Address,
+		"accrual", config.Accrual.Address,
+		"workers", config.Accrual.Workers,
+		"log_level", config.Log.Level,
+		"audit", config.Audit.Enabled,
+		"in_memory", config.DB.URI == "",
+	)
 
-	logger.Infoln("READY...")
-	logger.Infoln(fmt.Sprintf("Config: %#v", config))
+	// ключи проверяются до открытия ресурсов, которые пришлось бы закрывать при ошибке
+	keys, err := sec.BuildKeyRing(config.JWT.Secret, config.JWT.Keys, config.JWT.Kid)
 
-	service, err := service.NewStorageService(logger, config.DSN)
+	if err != nil {
+		return fmt.Errorf("CAN'T LOAD JWT KEYS [%w]", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Conf{
+		Exporter: config.Trace.Exporter,
+		Endpoint: config.Trace.Endpoint,
+	})
 
 	if err != nil {
+		return fmt.Errorf("CAN'T SETUP TRACING [%w]", err)
+	}
+
+	prom := metrics.NewProm()
+
+	storage, err := openStorage(logger, config, prom)
+
+	if err != nil {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Warnln("CAN'T SHUTDOWN TRACING:", err)
+		}
+
 		return err
 	}
 
-	jwt := sec.NewJwtProvider(config.SecretKeyTime, config.SecretKey)
+	jwt := sec.NewJwtProvider(config.JWT.TokenTTL, config.JWT.RefreshTTL, keys)
+
+	cookie := controller.NewCookieConf(config.HTTP.Cookie.Secure, config.HTTP.Cookie.SameSite, config.HTTP.Cookie.Path, config.HTTP.Cookie.Domain)
+
+	prom.RegisterOrderStats(storage.CountOrdersByStatus)
+
+	accrualClient := accrual.NewClient(config.Accrual.Address)
+	accrualClient.SetRateLimit(config.Accrual.RateLimit)
 
-	router := controller.NewRouter(logger, &service, jwt)
+	var accrualState controller.IAccrualState
+
+	if config.Accrual.Address != "" {
+		accrualState = accrualClient
+	}
+
+	var audit *controller.Auditor
+
+	if config.Audit.Enabled {
+		audit = controller.NewAuditor(logger, storage, config.Audit.Retention)
+	}
+
+	router := controller.NewRouter(logger, storage, jwt, controller.RouterConf{
+		Cookie:   cookie,
+		Compress: controller.CompressConf{MinSize: config.HTTP.CompressMin, Level: config.HTTP.CompressLevel},
+		Health:   controller.NewHealth(logger, storage, accrualState),
+		Metrics:  prom,
+		Audit:    audit,
+	})
 
 	server := &http.Server{
-		Addr:         config.BndAdr,
-		Handler:      router,
-		WriteTimeout: 30 * time.Second,
-		ReadTimeout:  30 * time.Second,
+		Addr:              config.HTTP.Address,
+		Handler:           router,
+		ReadTimeout:       config.HTTP.ReadTimeout,
+		ReadHeaderTimeout: config.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      config.HTTP.WriteTimeout,
+		IdleTimeout:       config.HTTP.IdleTimeout,
+	}
+
+	app := lifecycle.NewManager(logger, config.HTTP.ShutdownTimeout)
+
+	queue := controller.NewQueue(logger, storage, accrualClient, config.Accrual.Workers, config.Accrual.PullInterval)
+	queue.Metrics = prom
+
+	// хуки останавливаются в обратном порядке: сначала HTTP, затем воркеры, БД, трассировка и логгер
+	app.Append(lifecycle.Hook{
+		Name: "LOGGER",
+		Stop: func(context.Context) error {
+			return logger.Flush()
+		},
+	})
+
+	app.Append(lifecycle.Hook{
+		Name: "TRACING",
+		Stop: shutdownTracing,
+	})
+
+	app.Append(lifecycle.Hook{
+		Name: "DB",
+		Stop: func(context.Context) error {
+			return storage.Close()
+		},
+	})
+
+	app.Append(lifecycle.Hook{
+		Name: "ACCRUAL WORKERS",
+		Start: func(ctx context.Context) error {
+			if config.Accrual.Address == "" {
+				logger.Warnln("ACCRUAL SYSTEM ADDRESS IS EMPTY. ORDERS WON'T BE PROCESSED")
+				return nil
+			}
+
+			queue.StartWorkers(context.WithoutCancel(ctx))
+			return nil
+		},
+		Stop: queue.Stop,
+	})
+
+	if audit != nil {
+		app.Append(lifecycle.Hook{
+			Name: "REQUEST AUDIT",
+			Start: func(ctx context.Context) error {
+				audit.StartPurge(context.WithoutCancel(ctx))
+				return nil
+			},
+			Stop: audit.Stop,
+		})
 	}
 
-	logger.Infoln("START...")
-	if errServ := server.ListenAndServe(); errServ != nil {
-		return fmt.Errorf("CAN'T EXECUTE SERVER [%w]", errServ)
+	reloader := conf.NewReloader(config, load)
+
+	reloader.Subscribe(func(c conf.Config) {
+		if err := logger.SetLevel(c.Log.Level); err != nil {
+			logger.Warnln("CAN'T CHANGE LOG LEVEL:", err)
+		}
+
+		queue.Reconfigure(c.Accrual.Workers, c.Accrual.PullInterval)
+		accrualClient.SetRateLimit(c.Accrual.RateLimit)
+	})
+
+	hup := make(chan os.Signal, 1)
+
+	app.Append(lifecycle.Hook{
+		Name: "CONFIG RELOAD",
+		Start: func(context.Context) error {
+			signal.Notify(hup, syscall.SIGHUP)
+
+			go func() {
+				for range hup {
+					c, err := reloader.Reload()
+
+					if err != nil {
+						logger.Errorln("CONFIG NOT RELOADED:", err)
+						continue
+					}
+
+					for _, w := range c.Warnings {
+						logger.Warnln("CONFIG:", w)
+					}
+
+					logger.Infow("CONFIG RELOADED",
+						"log_level", c.Log.Level,
+						"workers", c.Accrual.Workers,
+						"pull_interval", c.Accrual.PullInterval,
+						"accrual_rate_limit", c.Accrual.RateLimit,
+					)
+				}
+			}()
+			return nil
+		},
+		Stop: func(context.Context) error {
+			signal.Stop(hup)
+			close(hup)
+			return nil
+		},
+	})
+
+	// метрики раскрывают число заказов по статусам и состояние пула БД, поэтому
+	// отдаются только на отдельном адресе, закрытом от клиентов API
+	if config.HTTP.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", prom.Handler())
+
+		metricsServer := &http.Server{
+			Addr:              config.HTTP.MetricsAddress,
+			Handler:           mux,
+			ReadHeaderTimeout: config.HTTP.ReadHeaderTimeout,
+		}
+
+		app.Append(lifecycle.Hook{
+			Name: "METRICS SERVER",
+			Start: func(context.Context) error {
+				go func() {
+					logger.Infoln("METRICS ON", config.HTTP.MetricsAddress)
+
+					if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						app.Fail(fmt.Errorf("CAN'T EXECUTE METRICS SERVER [%w]", err))
+					}
+				}()
+				return nil
+			},
+			Stop: metricsServer.Shutdown,
+		})
+	}
+
+	app.Append(lifecycle.Hook{
+		Name: "HTTP SERVER",
+		Start: func(context.Context) error {
+			go func() {
+				logger.Infoln("START...")
+
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					app.Fail(fmt.Errorf("CAN'T EXECUTE SERVER [%w]", err))
+				}
+			}()
+			return nil
+		},
+		Stop: server.Shutdown,
+	})
+
+	return app.Run(context.Background())
+}
+
+// openStorage подключается к PostgreSQL, а при пустом DSN создаёт хранилище в памяти.
+func openStorage(log logger.Lg, config conf.Config, prom *metrics.Prom) (backend, error) {
+	if config.DB.URI == "" {
+		log.Warnln("DATABASE URI IS EMPTY. DATA IS KEPT IN MEMORY AND WILL BE LOST ON RESTART")
+
+		mem, err := service.NewMemStorage(log)
+
+		if err != nil {
+			return nil, err
+		}
+
+		mem.Metrics = prom
+
+		return mem, nil
+	}
+
+	db, err := service.NewStorageService(log, config.DB.URI, poolConf(config))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.DB.AutoMigrate {
+		applied, err := db.MigrateUp(context.Background())
+
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+
+		if len(applied) > 0 {
+			log.Infow("MIGRATIONS APPLIED", "versions", applied)
+		}
+	} else {
+		log.Infoln("AUTO MIGRATION IS DISABLED")
+	}
+
+	db.Metrics = prom
+	prom.RegisterDBStats(db.Stats)
+
+	return &db, nil
+}
+
+func poolConf(config conf.Config) service.PoolConf {
+	return service.PoolConf{
+		MaxConns:        config.DB.MaxConns,
+		MinConns:        config.DB.MinConns,
+		ConnMaxLifetime: config.DB.ConnMaxLifetime,
+		ConnMaxIdleTime: config.DB.ConnMaxIdleTime,
 	}
-	return nil
 }
@@ -0,0 +1,293 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/DmitryM7/yapr56.git/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Status string
+
+const (
+	StatusRegistered Status = "REGISTERED"
+	StatusInvalid    Status = "INVALID"
+	StatusProcessing Status = "PROCESSING"
+	StatusProcessed  Status = "PROCESSED"
+
+	DefRetryAfter     = 60 * time.Second
+	defRequestTimeout = 10 * time.Second
+)
+
+var (
+	ErrNotRegistered   = errors.New("ORDER NOT REGISTERED IN ACCRUAL")
+	ErrTooManyRequests = errors.New("TOO MANY REQUESTS TO ACCRUAL")
+	ErrUnknownStatus   = errors.New("UNKNOWN ACCRUAL STATUS")
+)
+
+type (
+	Order struct {
+		Order   string       `json:"order"`
+		Status  Status       `json:"status"`
+		Accrual *money.Money `json:"accrual,omitempty"`
+	}
+
+	// Client ходит в систему расчёта начислений. Один экземпляр разделяется всеми
+	// воркерами: после ответа 429 пауза Retry-After действует на всех, а узнанный
+	// из тела ответа лимит N запросов в минуту равномерно распределяется между ними.
+	// Заданный через SetRateLimit лимит действует, пока сервис не сообщит более строгий.
+	Client struct {
+		Adr        string
+		client     *http.Client
+		mu         sync.Mutex
+		pauseUntil time.Time
+		nextSlot   time.Time
+		limit      int
+		rateLimit  int
+		lastSeen   time.Time
+		lastErr    string
+	}
+
+	// State — состояние связи с системой начислений для проверок готовности.
+	State struct {
+		PausedUntil time.Time
+		Limit       int
+		LastSeen    time.Time
+		LastError   string
+	}
+)
+
+func (s Status) IsFinal() bool {
+	return s == StatusInvalid || s == StatusProcessed
+}
+
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusRegistered, StatusInvalid, StatusProcessing, StatusProcessed:
+		return true
+	}
+	return false
+}
+
+// GetOrder возвращает расчёт по заказу. ErrNotRegistered — заказ неизвестен системе (204),
+// ErrTooManyRequests — сервис попросил подождать, последующие вызовы будут ждать сами.
+func (c *Client) GetOrder(ctx context.Context, number string) (Order, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "accrual.GetOrder",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.OrderNumber.String(number)))
+	defer span.End()
+
+	result, err := c.getOrder(ctx, number)
+
+	if err != nil && !errors.Is(err, ErrNotRegistered) {
+		return result, tracing.Fail(span, err)
+	}
+
+	span.SetAttributes(attribute.String("accrual.status", string(result.Status)))
+
+	return result, err
+}
+
+func (c *Client) getOrder(ctx context.Context, number string) (Order, error) {
+	result := Order{}
+
+	if err := c.wait(ctx); err != nil {
+		return result, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.Adr, "/")+"/api/orders/"+number, http.NoBody)
+
+	if err != nil {
+		return result, fmt.Errorf("CAN'T CREATE ACCRUAL REQUEST [%w]", err)
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+
+	c.observe(err)
+
+	if err != nil {
+		return result, fmt.Errorf("CAN'T DO ACCRUAL REQUEST [%w]", err)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return result, fmt.Errorf("CAN'T READ ACCRUAL BODY [%w]", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.Unmarshal(body, &result); err != nil {
+			return result, fmt.Errorf("CAN'T UNMARSHAL ACCRUAL BODY [%w]", err)
+		}
+
+		if !result.Status.IsValid() {
+			return result, fmt.Errorf("%w [%s]", ErrUnknownStatus, result.Status)
+		}
+
+		return result, nil
+	case http.StatusNoContent:
+		return result, ErrNotRegistered
+	case http.StatusTooManyRequests:
+		retryAfter := DefRetryAfter
+
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+
+		c.pause(retryAfter, parseLimit(string(body)))
+
+		return result, fmt.Errorf("%w: RETRY AFTER %v", ErrTooManyRequests, retryAfter)
+	default:
+		return result, fmt.Errorf("UNEXPECTED ACCRUAL RESPONCE CODE [%d]", resp.StatusCode)
+	}
+}
+
+// PausedUntil возвращает момент, до которого запросы к системе приостановлены.
+func (c *Client) PausedUntil() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pauseUntil
+}
+
+// Limit возвращает узнанный лимит запросов в минуту или 0, если он ещё неизвестен.
+func (c *Client) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// State возвращает паузу, лимит и итог последнего обращения к системе.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return State{
+		PausedUntil: c.pauseUntil,
+		Limit:       c.limit,
+		LastSeen:    c.lastSeen,
+		LastError:   c.lastErr,
+	}
+}
+
+// SetRateLimit задаёт собственный лимит запросов в минуту; 0 снимает его.
+// Может вызываться на ходу.
+func (c *Client) SetRateLimit(perMinute int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rateLimit = max(perMinute, 0)
+}
+
+// rate возвращает действующий лимит в минуту: меньший из заданного и узнанного.
+// Вызывается под mu.
+func (c *Client) rate() int {
+	if c.rateLimit > 0 && (c.limit == 0 || c.rateLimit < c.limit) {
+		return c.rateLimit
+	}
+
+	return c.limit
+}
+
+func (c *Client) observe(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.lastErr = err.Error()
+		return
+	}
+
+	c.lastSeen = time.Now()
+	c.lastErr = ""
+}
+
+func (c *Client) pause(d time.Duration, limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until := time.Now().Add(d)
+
+	if until.After(c.pauseUntil) {
+		c.pauseUntil = until
+	}
+
+	if limit > 0 {
+		c.limit = limit
+	}
+}
+
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+
+	now := time.Now()
+	at := c.pauseUntil
+
+	if rate := c.rate(); rate > 0 {
+		slot := c.nextSlot
+		if slot.Before(now) {
+			slot = now
+		}
+		if slot.Before(at) {
+			slot = at
+		}
+		c.nextSlot = slot.Add(time.Minute / time.Duration(rate))
+		at = slot
+	}
+
+	c.mu.Unlock()
+
+	d := time.Until(at)
+
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("ACCRUAL WAIT INTERRUPTED [%w]", ctx.Err())
+	}
+}
+
+// parseLimit достаёт N из тела "No more than N requests per minute allowed".
+func parseLimit(body string) int {
+	limit := 0
+
+	if _, err := fmt.Sscanf(strings.TrimSpace(body), "No more than %d requests per minute allowed", &limit); err != nil {
+		return 0
+	}
+
+	return limit
+}
+
+func NewClient(adr string) *Client {
+	return &Client{
+		Adr:    adr,
+		client: &http.Client{Timeout: defRequestTimeout},
+	}
+}
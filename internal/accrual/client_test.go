@@ -0,0 +1,113 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orders/12345678903":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"order":"12345678903","status":"PROCESSED","accrual":729.98}`))
+		case "/api/orders/9278923470":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"order":"9278923470","status":"REGISTERED"}`))
+		case "/api/orders/1":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"order":"1","status":"UNKNOWN"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+
+	order, err := client.GetOrder(context.Background(), "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, StatusProcessed, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, money.Money(72998), *order.Accrual)
+
+	order, err = client.GetOrder(context.Background(), "9278923470")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRegistered, order.Status)
+	assert.Nil(t, order.Accrual)
+
+	_, err = client.GetOrder(context.Background(), "79927398713")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+
+	_, err = client.GetOrder(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrUnknownStatus)
+}
+
+func TestClient_TooManyRequests(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("No more than 600 requests per minute allowed"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+
+	_, err := client.GetOrder(context.Background(), "12345678903")
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+	assert.Equal(t, 600, client.Limit())
+	assert.True(t, client.PausedUntil().After(time.Now()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = client.GetOrder(ctx, "12345678903")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), calls.Load())
+
+	start := time.Now()
+	_, err = client.GetOrder(context.Background(), "12345678903")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestClient_SetRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	client.SetRateLimit(300)
+
+	start := time.Now()
+
+	for range 3 {
+		_, err := client.GetOrder(context.Background(), "12345678903")
+		assert.ErrorIs(t, err, ErrNotRegistered)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond, "300 per minute is one request per 200ms")
+
+	client.SetRateLimit(0)
+
+	start = time.Now()
+	_, err := client.GetOrder(context.Background(), "12345678903")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+}
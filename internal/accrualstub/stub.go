@@ -0,0 +1,352 @@
+package accrualstub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/go-chi/chi"
+)
+
+const (
+	RewardPercent = "%"
+	RewardPoints  = "pt"
+
+	hundred = 100
+)
+
+var (
+	ErrEmptyOrder    = errors.New("EMPTY ORDER NUMBER")
+	ErrEmptyMatch    = errors.New("EMPTY GOODS MATCH")
+	ErrBadRewardType = errors.New("UNKNOWN REWARD TYPE")
+	ErrBadStatus     = errors.New("UNKNOWN ORDER STATUS IN SCRIPT")
+)
+
+var defScript = []accrual.Status{
+	accrual.StatusRegistered,
+	accrual.StatusProcessing,
+	accrual.StatusProcessed,
+}
+
+type (
+	Config struct {
+		RateLimit  int
+		RetryAfter time.Duration
+		DelayMin   time.Duration
+		DelayMax   time.Duration
+	}
+
+	// GoodsRule — правило начисления за товары, в описании которых встречается Match.
+	GoodsRule struct {
+		Match      string  `json:"match"`
+		Reward     float64 `json:"reward"`
+		RewardType string  `json:"reward_type"`
+	}
+
+	Goods struct {
+		Description string  `json:"description"`
+		Price       float64 `json:"price"`
+	}
+
+	// OrderRequest регистрирует заказ в заглушке. Начисление считается по Goods
+	// и правилам, если не задано явно в Accrual. Statuses — сценарий статусов,
+	// который заглушка отдаёт по одному на каждый запрос, повторяя последний.
+	OrderRequest struct {
+		Order    string           `json:"order"`
+		Goods    []Goods          `json:"goods,omitempty"`
+		Accrual  *money.Money     `json:"accrual,omitempty"`
+		Statuses []accrual.Status `json:"statuses,omitempty"`
+	}
+
+	order struct {
+		statuses []accrual.Status
+		accrual  *money.Money
+		step     int
+	}
+
+	Stub struct {
+		Log         logger.Lg
+		Conf        Config
+		mu          sync.Mutex
+		rules       []GoodsRule
+		orders      map[string]*order
+		windowStart time.Time
+		windowCount int
+	}
+)
+
+func (s *Stub) actOrder(w http.ResponseWriter, r *http.Request) {
+	if retryAfter, ok := s.allow(); !ok {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = fmt.Fprintf(w, "No more than %d requests per minute allowed", s.Conf.RateLimit)
+		return
+	}
+
+	s.delay()
+
+	resp, ok := s.next(chi.URLParam(r, "number"))
+
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	output, err := json.Marshal(resp)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.Log.Warnln("CAN'T MARSHAL ORDER:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(output)
+}
+
+func (s *Stub) actRegisterGoods(w http.ResponseWriter, r *http.Request) {
+	rule := GoodsRule{}
+
+	if err := readJSON(r, &rule); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.Log.Infoln("CAN'T READ GOODS RULE:", err)
+		return
+	}
+
+	if err := s.AddRule(rule); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.Log.Infoln("INVALID GOODS RULE:", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Stub) actRegisterOrder(w http.ResponseWriter, r *http.Request) {
+	req := OrderRequest{}
+
+	if err := readJSON(r, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.Log.Infoln("CAN'T READ ORDER:", err)
+		return
+	}
+
+	if err := s.AddOrder(req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.Log.Infoln("INVALID ORDER:", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Stub) actReset(w http.ResponseWriter, r *http.Request) {
+	s.Reset()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Stub) AddRule(rule GoodsRule) error {
+	if rule.Match == "" {
+		return ErrEmptyMatch
+	}
+
+	if rule.RewardType != RewardPercent && rule.RewardType != RewardPoints {
+		return fmt.Errorf("%w [%s]", ErrBadRewardType, rule.RewardType)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = append(s.rules, rule)
+
+	return nil
+}
+
+func (s *Stub) AddOrder(req OrderRequest) error {
+	if req.Order == "" {
+		return ErrEmptyOrder
+	}
+
+	statuses := req.Statuses
+
+	if len(statuses) == 0 {
+		statuses = defScript
+	}
+
+	for _, status := range statuses {
+		if !status.IsValid() {
+			return fmt.Errorf("%w [%s]", ErrBadStatus, status)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points := req.Accrual
+
+	if points == nil && len(req.Goods) > 0 {
+		sum := s.calcAccrual(req.Goods)
+		points = &sum
+	}
+
+	s.orders[req.Order] = &order{
+		statuses: statuses,
+		accrual:  points,
+	}
+
+	return nil
+}
+
+func (s *Stub) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = nil
+	s.orders = map[string]*order{}
+	s.windowCount = 0
+}
+
+func (s *Stub) calcAccrual(goods []Goods) money.Money {
+	sum := 0.0
+
+	for _, g := range goods {
+		for _, rule := range s.rules {
+			if !strings.Contains(g.Description, rule.Match) {
+				continue
+			}
+
+			if rule.RewardType == RewardPercent {
+				sum += g.Price * rule.Reward / hundred
+			} else {
+				sum += rule.Reward
+			}
+			break
+		}
+	}
+
+	return money.Money(math.Round(sum * money.Scale))
+}
+
+func (s *Stub) next(number string) (accrual.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[number]
+
+	if !ok {
+		return accrual.Order{}, false
+	}
+
+	step := o.step
+
+	if step >= len(o.statuses) {
+		step = len(o.statuses) - 1
+	} else {
+		o.step++
+	}
+
+	resp := accrual.Order{
+		Order:  number,
+		Status: o.statuses[step],
+	}
+
+	if resp.Status == accrual.StatusProcessed {
+		resp.Accrual = o.accrual
+	}
+
+	return resp, true
+}
+
+// allow считает запросы в окне длиной в минуту и сообщает, сколько ждать до его конца.
+func (s *Stub) allow() (time.Duration, bool) {
+	if s.Conf.RateLimit <= 0 {
+		return 0, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart = now
+		s.windowCount = 0
+	}
+
+	if s.windowCount >= s.Conf.RateLimit {
+		if s.Conf.RetryAfter > 0 {
+			return s.Conf.RetryAfter, false
+		}
+		return s.windowStart.Add(time.Minute).Sub(now), false
+	}
+
+	s.windowCount++
+
+	return 0, true
+}
+
+func (s *Stub) delay() {
+	if s.Conf.DelayMax <= 0 || s.Conf.DelayMax < s.Conf.DelayMin {
+		return
+	}
+
+	d := s.Conf.DelayMin
+
+	if spread := s.Conf.DelayMax - s.Conf.DelayMin; spread > 0 {
+		d += time.Duration(rand.Int63n(int64(spread))) //nolint:gosec //It's a test stub
+	}
+
+	time.Sleep(d)
+}
+
+func readJSON(r *http.Request, v any) error {
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		return fmt.Errorf("CAN'T READ BODY [%w]", err)
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("CAN'T UNMARSHAL BODY [%w]", err)
+	}
+
+	return nil
+}
+
+func NewStub(log logger.Lg, conf Config) *Stub {
+	return &Stub{
+		Log:    log,
+		Conf:   conf,
+		orders: map[string]*order{},
+	}
+}
+
+func NewRouter(stub *Stub) *chi.Mux {
+	R := chi.NewRouter()
+
+	R.Route("/api", func(r chi.Router) {
+		r.Get("/orders/{number}", stub.actOrder)
+		r.Post("/orders", stub.actRegisterOrder)
+		r.Post("/goods", stub.actRegisterGoods)
+		r.Post("/reset", stub.actReset)
+	})
+
+	return R
+}
@@ -0,0 +1,73 @@
+package accrualstub
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStub_Script(t *testing.T) {
+	stub := NewStub(logger.NewLg(), Config{})
+	srv := httptest.NewServer(NewRouter(stub))
+	defer srv.Close()
+
+	require.NoError(t, stub.AddRule(GoodsRule{Match: "Bork", Reward: 10, RewardType: RewardPercent}))
+	require.NoError(t, stub.AddRule(GoodsRule{Match: "Cup", Reward: 15, RewardType: RewardPoints}))
+	require.NoError(t, stub.AddOrder(OrderRequest{
+		Order: "12345678903",
+		Goods: []Goods{{Description: "Чайник Bork", Price: 7299.8}, {Description: "Cup", Price: 100}},
+	}))
+	require.NoError(t, stub.AddOrder(OrderRequest{
+		Order:    "9278923470",
+		Statuses: []accrual.Status{accrual.StatusInvalid},
+	}))
+
+	client := accrual.NewClient(srv.URL)
+	ctx := context.Background()
+
+	for _, want := range []accrual.Status{accrual.StatusRegistered, accrual.StatusProcessing, accrual.StatusProcessed, accrual.StatusProcessed} {
+		order, err := client.GetOrder(ctx, "12345678903")
+		require.NoError(t, err)
+		assert.Equal(t, want, order.Status)
+	}
+
+	order, err := client.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, money.Money(74498), *order.Accrual)
+
+	order, err = client.GetOrder(ctx, "9278923470")
+	require.NoError(t, err)
+	assert.Equal(t, accrual.StatusInvalid, order.Status)
+	assert.Nil(t, order.Accrual)
+
+	_, err = client.GetOrder(ctx, "79927398713")
+	assert.ErrorIs(t, err, accrual.ErrNotRegistered)
+
+	assert.ErrorIs(t, stub.AddOrder(OrderRequest{Order: "1", Statuses: []accrual.Status{"DONE"}}), ErrBadStatus)
+	assert.ErrorIs(t, stub.AddRule(GoodsRule{Match: "Bork", RewardType: "x"}), ErrBadRewardType)
+}
+
+func TestStub_RateLimit(t *testing.T) {
+	stub := NewStub(logger.NewLg(), Config{RateLimit: 2})
+	srv := httptest.NewServer(NewRouter(stub))
+	defer srv.Close()
+
+	client := accrual.NewClient(srv.URL)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetOrder(ctx, "12345678903")
+		assert.ErrorIs(t, err, accrual.ErrNotRegistered)
+	}
+
+	_, err := client.GetOrder(ctx, "12345678903")
+	assert.ErrorIs(t, err, accrual.ErrTooManyRequests)
+	assert.Equal(t, 2, client.Limit())
+}
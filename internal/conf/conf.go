@@ -2,55 +2,278 @@ package conf
 
 import (
 	"flag"
-	"os"
-	"strconv"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
-const defaultSecretKeyTime = 25
+const (
+	defaultAddress       = "localhost:8080"
+	defaultSecretKeyTime = 25 * time.Minute
+	defaultWorkers       = 3
+	defaultPullInterval  = 5 * time.Second
+	defaultRefreshTime   = 30 * 24 * time.Hour
+	defaultCompressSize  = 256
+	defaultShutdownTime  = 10 * time.Second
+	defaultLogSampling   = 100
+	defaultAuditTime     = 90 * 24 * time.Hour
 
-type Config struct {
-	BndAdr        string
-	DSN           string
-	SecretKey     string
-	SecretKeyTime time.Duration
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+
+	defaultMaxConns        = 20
+	defaultMinConns        = 2
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// KeyFiles — набор "kid=путь,kid=путь" для флага и переменной окружения.
+type KeyFiles map[string]string
+
+func (k *KeyFiles) String() string {
+	parts := make([]string, 0, len(*k))
+	for kid, path := range *k {
+		parts = append(parts, kid+"="+path)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
 }
 
-func (s *Config) ParseFlags() {
-	flag.StringVar(&s.BndAdr, "a", "localhost:8080", "host where server is run")
-	flag.StringVar(&s.DSN, "d", "", "database dsn")
-	flag.StringVar(&s.SecretKey, "k", "", "Secret key for JWT")
-	flag.DurationVar(&s.SecretKeyTime, "kt", defaultSecretKeyTime*time.Minute, "Time secret key in minutes")
+func (k *KeyFiles) Set(value string) error {
+	if *k == nil {
+		*k = KeyFiles{}
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		kid, path, ok := strings.Cut(item, "=")
+
+		if !ok || kid == "" || path == "" {
+			return fmt.Errorf("INVALID JWT KEY [%s], WANT kid=path", item)
+		}
+
+		(*k)[kid] = path
+	}
+
+	return nil
 }
 
-func (s *Config) ParseEnv() {
-	if env := os.Getenv("SERVER_ADDRESS"); env != "" {
-		s.BndAdr = env
+type (
+	CookieConf struct {
+		Secure   bool   `yaml:"secure"`
+		SameSite string `yaml:"same_site"`
+		Path     string `yaml:"path"`
+		Domain   string `yaml:"domain"`
 	}
 
-	if env := os.Getenv("DATABASE_DSN"); env != "" {
-		s.DSN = env
+	HTTPConf struct {
+		Address           string        `yaml:"address"`
+		MetricsAddress    string        `yaml:"metrics_address"`
+		ReadTimeout       time.Duration `yaml:"read_timeout"`
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+		WriteTimeout      time.Duration `yaml:"write_timeout"`
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`
+		ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+		CompressMin       int           `yaml:"compress_min"`
+		CompressLevel     int           `yaml:"compress_level"`
+		Cookie            CookieConf    `yaml:"cookie"`
 	}
 
-	if env := os.Getenv("SECRET_KEY"); env != "" {
-		s.SecretKey = env
+	DBConf struct {
+		URI             string        `yaml:"uri"`
+		MaxConns        int           `yaml:"max_conns"`
+		MinConns        int           `yaml:"min_conns"`
+		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+		ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+		AutoMigrate     bool          `yaml:"auto_migrate"`
 	}
 
-	if env := os.Getenv("SECRET_KEY_TIME"); env != "" {
-		duration, err := strconv.Atoi(env)
+	AccrualConf struct {
+		Address      string        `yaml:"address"`
+		Workers      int           `yaml:"workers"`
+		PullInterval time.Duration `yaml:"pull_interval"`
+		RateLimit    int           `yaml:"rate_limit"`
+	}
 
-		if err == nil {
-			s.SecretKeyTime = time.Duration(duration) * time.Minute
-		} else {
-			s.SecretKeyTime = time.Duration(defaultSecretKeyTime) * time.Minute
-		}
+	JWTConf struct {
+		Secret     string        `yaml:"secret"`
+		TokenTTL   time.Duration `yaml:"token_ttl"`
+		RefreshTTL time.Duration `yaml:"refresh_ttl"`
+		Keys       KeyFiles      `yaml:"keys"`
+		Kid        string        `yaml:"kid"`
+	}
+
+	LogConf struct {
+		Level    string `yaml:"level"`
+		Format   string `yaml:"format"`
+		Sampling int    `yaml:"sampling"`
+	}
+
+	TraceConf struct {
+		Exporter string `yaml:"exporter"`
+		Endpoint string `yaml:"endpoint"`
+	}
+
+	AuditConf struct {
+		Enabled   bool          `yaml:"enabled"`
+		Retention time.Duration `yaml:"retention"`
+	}
+
+	// Config собирается в порядке возрастания приоритета: значения по умолчанию,
+	// файл (-config, CONFIG), переменные окружения, флаги командной строки.
+	Config struct {
+		File    string      `yaml:"-"`
+		HTTP    HTTPConf    `yaml:"http"`
+		DB      DBConf      `yaml:"db"`
+		Accrual AccrualConf `yaml:"accrual"`
+		JWT     JWTConf     `yaml:"jwt"`
+		Log     LogConf     `yaml:"log"`
+		Trace   TraceConf   `yaml:"trace"`
+		Audit   AuditConf   `yaml:"audit"`
+
+		// Warnings — замечания, которые стоит записать в журнал после его настройки,
+		// например об устаревших переменных окружения.
+		Warnings []string `yaml:"-"`
+
+		// Args — позиционные аргументы, оставшиеся после флагов.
+		Args []string `yaml:"-"`
+
+		errs []error
+	}
+)
+
+func Default() Config {
+	return Config{
+		HTTP: HTTPConf{
+			Address:           defaultAddress,
+			ReadTimeout:       defaultReadTimeout,
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			WriteTimeout:      defaultWriteTimeout,
+			IdleTimeout:       defaultIdleTimeout,
+			ShutdownTimeout:   defaultShutdownTime,
+			CompressMin:       defaultCompressSize,
+			Cookie: CookieConf{
+				SameSite: "lax",
+				Path:     "/",
+			},
+		},
+		DB: DBConf{
+			MaxConns:        defaultMaxConns,
+			MinConns:        defaultMinConns,
+			ConnMaxLifetime: defaultConnMaxLifetime,
+			ConnMaxIdleTime: defaultConnMaxIdleTime,
+			AutoMigrate:     true,
+		},
+		Accrual: AccrualConf{
+			Workers:      defaultWorkers,
+			PullInterval: defaultPullInterval,
+		},
+		JWT: JWTConf{
+			TokenTTL:   defaultSecretKeyTime,
+			RefreshTTL: defaultRefreshTime,
+		},
+		Log: LogConf{
+			Level:    "info",
+			Format:   "console",
+			Sampling: defaultLogSampling,
+		},
+		Trace: TraceConf{
+			Exporter: "none",
+		},
+		Audit: AuditConf{
+			Retention: defaultAuditTime,
+		},
 	}
 }
 
-func NewConf() Config {
-	c := Config{}
-	c.ParseFlags()
-	flag.Parse()
-	c.ParseEnv()
-	return c
+func (s *Config) ParseFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.File, "config", s.File, "path to YAML or JSON config file")
+
+	fs.StringVar(&s.HTTP.Address, "a", s.HTTP.Address, "host where server is run")
+	fs.StringVar(&s.HTTP.MetricsAddress, "metrics-address", s.HTTP.MetricsAddress, "host:port of separate listener for /metrics, empty disables metrics endpoint")
+	fs.DurationVar(&s.HTTP.ReadTimeout, "http-read-timeout", s.HTTP.ReadTimeout, "HTTP read timeout")
+	fs.DurationVar(&s.HTTP.ReadHeaderTimeout, "http-read-header-timeout", s.HTTP.ReadHeaderTimeout, "HTTP read header timeout")
+	fs.DurationVar(&s.HTTP.WriteTimeout, "http-write-timeout", s.HTTP.WriteTimeout, "HTTP write timeout")
+	fs.DurationVar(&s.HTTP.IdleTimeout, "http-idle-timeout", s.HTTP.IdleTimeout, "HTTP keep-alive idle timeout")
+	fs.DurationVar(&s.HTTP.ShutdownTimeout, "st", s.HTTP.ShutdownTimeout, "graceful shutdown timeout")
+	fs.IntVar(&s.HTTP.CompressMin, "cmin", s.HTTP.CompressMin, "minimal response size in bytes to compress")
+	fs.IntVar(&s.HTTP.CompressLevel, "clevel", s.HTTP.CompressLevel, "gzip/deflate compression level, 0 means default")
+	fs.BoolVar(&s.HTTP.Cookie.Secure, "cookie-secure", s.HTTP.Cookie.Secure, "set Secure attribute on auth cookies")
+	fs.StringVar(&s.HTTP.Cookie.SameSite, "cookie-samesite", s.HTTP.Cookie.SameSite, "SameSite attribute of auth cookies: lax, strict or none")
+	fs.StringVar(&s.HTTP.Cookie.Path, "cookie-path", s.HTTP.Cookie.Path, "Path attribute of auth cookie")
+	fs.StringVar(&s.HTTP.Cookie.Domain, "cookie-domain", s.HTTP.Cookie.Domain, "Domain attribute of auth cookies")
+
+	fs.StringVar(&s.DB.URI, "d", s.DB.URI, "database URI, empty keeps data in memory")
+	fs.IntVar(&s.DB.MaxConns, "db-max-conns", s.DB.MaxConns, "max DB pool size, 0 keeps pgx default")
+	fs.IntVar(&s.DB.MinConns, "db-min-conns", s.DB.MinConns, "DB connections kept open when idle")
+	fs.DurationVar(&s.DB.ConnMaxLifetime, "db-conn-lifetime", s.DB.ConnMaxLifetime, "max lifetime of DB connection")
+	fs.DurationVar(&s.DB.ConnMaxIdleTime, "db-conn-idle-time", s.DB.ConnMaxIdleTime, "max idle time of DB connection")
+	fs.BoolVar(&s.DB.AutoMigrate, "db-auto-migrate", s.DB.AutoMigrate, "apply pending migrations when serve starts")
+
+	fs.StringVar(&s.Accrual.Address, "r", s.Accrual.Address, "accrual system address")
+	fs.IntVar(&s.Accrual.Workers, "w", s.Accrual.Workers, "count of accrual workers")
+	fs.DurationVar(&s.Accrual.PullInterval, "pi", s.Accrual.PullInterval, "interval between accrual queue pulls")
+	fs.IntVar(&s.Accrual.RateLimit, "accrual-rate-limit", s.Accrual.RateLimit, "max requests per minute to accrual system, 0 means only the limit it reports")
+
+	fs.StringVar(&s.JWT.Secret, "k", s.JWT.Secret, "Secret key for JWT")
+	fs.DurationVar(&s.JWT.TokenTTL, "kt", s.JWT.TokenTTL, "access token lifetime")
+	fs.DurationVar(&s.JWT.RefreshTTL, "rt", s.JWT.RefreshTTL, "refresh token lifetime")
+	fs.Var(&s.JWT.Keys, "jwt-keys", "PEM keys for JWT as kid=path,kid=path")
+	fs.StringVar(&s.JWT.Kid, "jwt-kid", s.JWT.Kid, "kid of the key used to sign JWT")
+
+	fs.StringVar(&s.Log.Level, "log-level", s.Log.Level, "log level: debug, info, warn or error")
+	fs.StringVar(&s.Log.Format, "log-format", s.Log.Format, "log format: console or json")
+	fs.IntVar(&s.Log.Sampling, "log-sampling", s.Log.Sampling, "log first N equal entries per second, then every Nth; 0 disables sampling")
+
+	fs.StringVar(&s.Trace.Exporter, "trace-exporter", s.Trace.Exporter, "trace exporter: none, stdout or otlp")
+	fs.StringVar(&s.Trace.Endpoint, "trace-endpoint", s.Trace.Endpoint, "OTLP/HTTP endpoint as host:port or URL")
+
+	fs.BoolVar(&s.Audit.Enabled, "audit", s.Audit.Enabled, "persist bodies of order upload and withdraw requests")
+	fs.DurationVar(&s.Audit.Retention, "audit-retention", s.Audit.Retention, "how long to keep persisted requests, 0 keeps forever")
+}
+
+// Load собирает конфигурацию из args и окружения и проверяет её для scope. Флаги разбираются
+// дважды: сначала, чтобы узнать путь к файлу, затем поверх файла и окружения, чтобы
+// явно заданный флаг имел наивысший приоритет.
+func Load(name string, scope Scope, args []string, getenv func(string) string) (Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	c.ParseFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	explicit := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if _, ok := explicit["config"]; !ok {
+		c.File = getenv("CONFIG")
+	}
+
+	if c.File != "" {
+		if err := c.ParseFile(c.File); err != nil {
+			return c, err
+		}
+	}
+
+	c.ParseEnv(getenv)
+	c.Args = fs.Args()
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			c.errs = append(c.errs, fmt.Errorf("FLAG -%s: [%w]", name, err))
+		}
+	}
+
+	return c, c.Validate(scope)
 }
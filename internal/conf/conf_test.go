@@ -0,0 +1,133 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func envOf(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoad_precedence(t *testing.T) {
+	path := writeFile(t, "gophermart.yaml", `
+http:
+  address: file:1
+  read_timeout: 7s
+db:
+  uri: postgres://file
+  max_conns: 50
+accrual:
+  address: http://file:8080
+  workers: 8
+jwt:
+  secret: `+testSecret+`
+log:
+  level: debug
+`)
+
+	c, err := Load("test", ScopeServe, []string{"-config", path, "-a", "flag:3"}, envOf(map[string]string{
+		"RUN_ADDRESS":  "env:2",
+		"DATABASE_URI": "postgres://env",
+		"WORKERS":      "4",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "flag:3", c.HTTP.Address, "flag wins over env and file")
+	assert.Equal(t, "postgres://env", c.DB.URI, "env wins over file")
+	assert.Equal(t, 4, c.Accrual.Workers, "env wins over file")
+	assert.Equal(t, 7*time.Second, c.HTTP.ReadTimeout, "file wins over defaults")
+	assert.Equal(t, 50, c.DB.MaxConns)
+	assert.Equal(t, "debug", c.Log.Level)
+	assert.Equal(t, defaultWriteTimeout, c.HTTP.WriteTimeout, "defaults stay")
+}
+
+func TestLoad_jsonFile(t *testing.T) {
+	path := writeFile(t, "gophermart.json", "{\n\t\"db\": {\"uri\": \"postgres://json\"},\n\t\"jwt\": {\"secret\": \""+testSecret+"\", \"token_ttl\": \"1h\"}\n}")
+
+	c, err := Load("test", ScopeServe, nil, envOf(map[string]string{"CONFIG": path}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres://json", c.DB.URI)
+	assert.Equal(t, time.Hour, c.JWT.TokenTTL)
+	assert.Equal(t, path, c.File)
+}
+
+func TestLoad_deprecatedEnv(t *testing.T) {
+	c, err := Load("test", ScopeServe, nil, envOf(map[string]string{
+		"SERVER_ADDRESS": "old:1",
+		"DATABASE_DSN":   "postgres://old",
+		"DATABASE_URI":   "postgres://new",
+		"SECRET_KEY":     testSecret,
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "old:1", c.HTTP.Address)
+	assert.Equal(t, "postgres://new", c.DB.URI)
+	assert.Len(t, c.Warnings, 2)
+}
+
+func TestLoad_args(t *testing.T) {
+	c, err := Load("test", ScopeServe, []string{"-db-auto-migrate=false", "alice", "bob"}, envOf(map[string]string{"SECRET_KEY": testSecret}))
+	require.NoError(t, err)
+
+	assert.False(t, c.DB.AutoMigrate)
+	assert.Equal(t, []string{"alice", "bob"}, c.Args)
+	assert.True(t, Default().DB.AutoMigrate, "serve migrates by default")
+}
+
+func TestLoad_validate(t *testing.T) {
+	_, err := Load("test", ScopeServe, []string{"-w", "0"}, envOf(map[string]string{
+		"RUN_ADDRESS":            "no-port",
+		"METRICS_ADDRESS":        "no-port-either",
+		"ACCRUAL_SYSTEM_ADDRESS": "localhost:8081",
+		"LOG_LEVEL":              "loud",
+		"SHUTDOWN_TIMEOUT":       "soon",
+	}))
+	require.ErrorIs(t, err, ErrInvalidConfig)
+
+	for _, want := range []string{"RUN ADDRESS", "METRICS ADDRESS", "ACCRUAL SYSTEM ADDRESS", "WORKERS", "JWT SECRET", "LOG LEVEL", "SHUTDOWN_TIMEOUT"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoad_adminScope(t *testing.T) {
+	_, err := Load("test", ScopeAdmin, []string{"-a", "no-port"}, envOf(nil))
+	require.NoError(t, err, "admin commands need neither HTTP address nor JWT keys")
+
+	_, err = Load("test", ScopeAdmin, nil, envOf(map[string]string{"LOG_LEVEL": "loud"}))
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestLoad_unknownFileKey(t *testing.T) {
+	path := writeFile(t, "gophermart.yaml", "http:\n  adress: localhost:1\n")
+
+	_, err := Load("test", ScopeServe, []string{"-config", path}, envOf(nil))
+	assert.ErrorContains(t, err, "adress")
+}
+
+func TestLoad_exampleFile(t *testing.T) {
+	c, err := Load("test", ScopeServe, []string{"-config", "../../cmd/gophermart/gophermart.example.yaml"}, envOf(map[string]string{"SECRET_KEY": testSecret}))
+	require.NoError(t, err)
+
+	assert.Equal(t, Default().HTTP, c.HTTP)
+	assert.Equal(t, "http://localhost:8081", c.Accrual.Address)
+}
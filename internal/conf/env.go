@@ -0,0 +1,149 @@
+package conf
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// env читает переменные окружения в поля конфигурации. Ошибки разбора копятся
+// в errs и выводятся Validate вместе с остальными.
+type env struct {
+	getenv func(string) string
+	c      *Config
+}
+
+func (s *Config) ParseEnv(getenv func(string) string) {
+	e := env{getenv: getenv, c: s}
+
+	e.deprecated("RUN_ADDRESS", "SERVER_ADDRESS", &s.HTTP.Address)
+	e.str("METRICS_ADDRESS", &s.HTTP.MetricsAddress)
+	e.duration("HTTP_READ_TIMEOUT", &s.HTTP.ReadTimeout)
+	e.duration("HTTP_READ_HEADER_TIMEOUT", &s.HTTP.ReadHeaderTimeout)
+	e.duration("HTTP_WRITE_TIMEOUT", &s.HTTP.WriteTimeout)
+	e.duration("HTTP_IDLE_TIMEOUT", &s.HTTP.IdleTimeout)
+	e.duration("SHUTDOWN_TIMEOUT", &s.HTTP.ShutdownTimeout)
+	e.integer("COMPRESS_MIN_SIZE", &s.HTTP.CompressMin)
+	e.integer("COMPRESS_LEVEL", &s.HTTP.CompressLevel)
+	e.boolean("COOKIE_SECURE", &s.HTTP.Cookie.Secure)
+	e.str("COOKIE_SAMESITE", &s.HTTP.Cookie.SameSite)
+	e.str("COOKIE_PATH", &s.HTTP.Cookie.Path)
+	e.str("COOKIE_DOMAIN", &s.HTTP.Cookie.Domain)
+
+	e.deprecated("DATABASE_URI", "DATABASE_DSN", &s.DB.URI)
+	e.integer("DB_MAX_CONNS", &s.DB.MaxConns)
+	e.integer("DB_MIN_CONNS", &s.DB.MinConns)
+	e.duration("DB_CONN_MAX_LIFETIME", &s.DB.ConnMaxLifetime)
+	e.duration("DB_CONN_MAX_IDLE_TIME", &s.DB.ConnMaxIdleTime)
+	e.boolean("DB_AUTO_MIGRATE", &s.DB.AutoMigrate)
+
+	e.str("ACCRUAL_SYSTEM_ADDRESS", &s.Accrual.Address)
+	e.integer("WORKERS", &s.Accrual.Workers)
+	e.duration("PULL_INTERVAL", &s.Accrual.PullInterval)
+	e.integer("ACCRUAL_RATE_LIMIT", &s.Accrual.RateLimit)
+
+	e.str("SECRET_KEY", &s.JWT.Secret)
+	e.minutes("SECRET_KEY_TIME", &s.JWT.TokenTTL)
+	e.duration("REFRESH_TOKEN_TIME", &s.JWT.RefreshTTL)
+	e.str("JWT_SIGNING_KID", &s.JWT.Kid)
+
+	if v := getenv("JWT_KEYS"); v != "" {
+		s.JWT.Keys = KeyFiles{}
+
+		if err := s.JWT.Keys.Set(v); err != nil {
+			s.errs = append(s.errs, fmt.Errorf("JWT_KEYS: [%w]", err))
+		}
+	}
+
+	e.str("LOG_LEVEL", &s.Log.Level)
+	e.str("LOG_FORMAT", &s.Log.Format)
+	e.integer("LOG_SAMPLING", &s.Log.Sampling)
+
+	e.str("TRACE_EXPORTER", &s.Trace.Exporter)
+	e.str("TRACE_ENDPOINT", &s.Trace.Endpoint)
+
+	e.boolean("AUDIT_REQUESTS", &s.Audit.Enabled)
+	e.duration("AUDIT_RETENTION", &s.Audit.Retention)
+}
+
+func (e env) str(name string, dst *string) {
+	if v := e.getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+// deprecated читает переменную по имени из спецификации, а при её отсутствии —
+// по старому имени, предупреждая об этом.
+func (e env) deprecated(name, old string, dst *string) {
+	if v := e.getenv(old); v != "" {
+		if e.getenv(name) == "" {
+			*dst = v
+			e.c.Warnings = append(e.c.Warnings, fmt.Sprintf("%s IS DEPRECATED, USE %s", old, name))
+		} else {
+			e.c.Warnings = append(e.c.Warnings, fmt.Sprintf("%s IS IGNORED, %s IS SET", old, name))
+		}
+	}
+
+	e.str(name, dst)
+}
+
+func (e env) integer(name string, dst *int) {
+	v := e.getenv(name)
+
+	if v == "" {
+		return
+	}
+
+	n, err := strconv.Atoi(v)
+
+	if err != nil {
+		e.c.errs = append(e.c.errs, fmt.Errorf("%s: NOT AN INTEGER [%s]", name, v))
+		return
+	}
+
+	*dst = n
+}
+
+func (e env) boolean(name string, dst *bool) {
+	v := e.getenv(name)
+
+	if v == "" {
+		return
+	}
+
+	b, err := strconv.ParseBool(v)
+
+	if err != nil {
+		e.c.errs = append(e.c.errs, fmt.Errorf("%s: NOT A BOOLEAN [%s]", name, v))
+		return
+	}
+
+	*dst = b
+}
+
+func (e env) duration(name string, dst *time.Duration) {
+	v := e.getenv(name)
+
+	if v == "" {
+		return
+	}
+
+	d, err := time.ParseDuration(v)
+
+	if err != nil {
+		e.c.errs = append(e.c.errs, fmt.Errorf("%s: NOT A DURATION [%s]", name, v))
+		return
+	}
+
+	*dst = d
+}
+
+// minutes принимает и число минут, как раньше, и длительность вида 25m.
+func (e env) minutes(name string, dst *time.Duration) {
+	if n, err := strconv.Atoi(e.getenv(name)); err == nil {
+		*dst = time.Duration(n) * time.Minute
+		return
+	}
+
+	e.duration(name, dst)
+}
@@ -0,0 +1,32 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseFile читает YAML- или JSON-файл поверх текущих значений. JSON — подмножество
+// YAML, поэтому оба формата разбираются одним декодером; длительности задаются
+// строками вида "30s". Неизвестные ключи считаются ошибкой, чтобы опечатка не
+// оставалась незамеченной.
+func (s *Config) ParseFile(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("CAN'T READ CONFIG FILE [%w]", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("CAN'T PARSE CONFIG FILE %s [%w]", path, err)
+	}
+
+	return nil
+}
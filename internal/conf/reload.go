@@ -0,0 +1,101 @@
+package conf
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// Reloader хранит действующую конфигурацию и по Reload перечитывает её, рассылая
+// подписчикам. Поля, которые применяются только при запуске (адрес, БД, ключи JWT
+// и т.п.), сохраняют прежние значения, а об их изменении сообщается в Warnings.
+type Reloader struct {
+	load func() (Config, error)
+	cur  atomic.Pointer[Config]
+	mu   sync.Mutex
+	subs []func(Config)
+}
+
+// NewReloader создаёт Reloader с уже загруженной конфигурацией c. load повторяет
+// загрузку с теми же аргументами и окружением, например через Load.
+func NewReloader(c Config, load func() (Config, error)) *Reloader {
+	r := &Reloader{load: load}
+	r.cur.Store(&c)
+
+	return r
+}
+
+// Current возвращает действующую конфигурацию.
+func (r *Reloader) Current() Config {
+	return *r.cur.Load()
+}
+
+// Subscribe добавляет получателя новой конфигурации. Получатели вызываются
+// по очереди после каждой успешной перезагрузки.
+func (r *Reloader) Subscribe(fn func(Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subs = append(r.subs, fn)
+}
+
+// Reload перечитывает конфигурацию, проверяет её и публикует. Если новая
+// конфигурация не прошла проверку, действующая остаётся без изменений.
+func (r *Reloader) Reload() (Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur := r.Current()
+
+	next, err := r.load()
+
+	if err != nil {
+		return cur, fmt.Errorf("CAN'T RELOAD CONFIG [%w]", err)
+	}
+
+	next.Warnings = keepStatic(cur, &next)
+
+	r.cur.Store(&next)
+
+	for _, fn := range r.subs {
+		fn(next)
+	}
+
+	return next, nil
+}
+
+// keepStatic возвращает в next значения cur для полей, которые нельзя поменять
+// на ходу, и перечисляет изменённые из них.
+func keepStatic(cur Config, next *Config) []string {
+	var warnings []string
+
+	keep := func(name string, changed bool) {
+		if changed {
+			warnings = append(warnings, name+" CHANGED, RESTART REQUIRED TO APPLY")
+		}
+	}
+
+	keep("HTTP SETTINGS", cur.HTTP != next.HTTP)
+	keep("DATABASE SETTINGS", cur.DB != next.DB)
+	keep("ACCRUAL SYSTEM ADDRESS", cur.Accrual.Address != next.Accrual.Address)
+	keep("JWT SETTINGS", !jwtEqual(cur.JWT, next.JWT))
+	keep("LOG FORMAT", cur.Log.Format != next.Log.Format || cur.Log.Sampling != next.Log.Sampling)
+	keep("TRACE SETTINGS", cur.Trace != next.Trace)
+	keep("AUDIT SETTINGS", cur.Audit != next.Audit)
+
+	next.HTTP = cur.HTTP
+	next.DB = cur.DB
+	next.Accrual.Address = cur.Accrual.Address
+	next.JWT = cur.JWT
+	next.Log.Format, next.Log.Sampling = cur.Log.Format, cur.Log.Sampling
+	next.Trace = cur.Trace
+	next.Audit = cur.Audit
+
+	return warnings
+}
+
+func jwtEqual(a, b JWTConf) bool {
+	return a.Secret == b.Secret && a.TokenTTL == b.TokenTTL && a.RefreshTTL == b.RefreshTTL &&
+		a.Kid == b.Kid && maps.Equal(a.Keys, b.Keys)
+}
@@ -0,0 +1,77 @@
+package conf
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	path := writeFile(t, "gophermart.yaml", `
+http:
+  address: localhost:1
+db:
+  uri: postgres://old
+accrual:
+  workers: 2
+log:
+  level: info
+`)
+
+	load := func() (Config, error) {
+		return Load("test", ScopeServe, []string{"-config", path}, envOf(map[string]string{"SECRET_KEY": testSecret}))
+	}
+
+	c, err := load()
+	require.NoError(t, err)
+
+	r := NewReloader(c, load)
+
+	var got []Config
+	r.Subscribe(func(c Config) {
+		got = append(got, c)
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+http:
+  address: localhost:2
+db:
+  uri: postgres://new
+  max_conns: 7
+accrual:
+  workers: 5
+  pull_interval: 1s
+  rate_limit: 120
+log:
+  level: debug
+`), 0o600))
+
+	next, err := r.Reload()
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, next, got[0])
+	assert.Equal(t, next, r.Current())
+
+	assert.Equal(t, "debug", next.Log.Level)
+	assert.Equal(t, 5, next.Accrual.Workers)
+	assert.Equal(t, time.Second, next.Accrual.PullInterval)
+	assert.Equal(t, 120, next.Accrual.RateLimit)
+
+	assert.Equal(t, "localhost:1", next.HTTP.Address, "bind address needs restart")
+	assert.Equal(t, "postgres://old", next.DB.URI, "DSN needs restart")
+	assert.Equal(t, Default().DB.MaxConns, next.DB.MaxConns, "pool size needs restart")
+	assert.Equal(t, []string{
+		"HTTP SETTINGS CHANGED, RESTART REQUIRED TO APPLY",
+		"DATABASE SETTINGS CHANGED, RESTART REQUIRED TO APPLY",
+	}, next.Warnings)
+
+	require.NoError(t, os.WriteFile(path, []byte("accrual:\n  workers: 0\n"), 0o600))
+
+	_, err = r.Reload()
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Len(t, got, 1, "invalid config isn't published")
+	assert.Equal(t, next, r.Current())
+}
@@ -0,0 +1,103 @@
+package conf
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/DmitryM7/yapr56.git/internal/sec"
+)
+
+var ErrInvalidConfig = errors.New("INVALID CONFIG")
+
+// Scope выбирает проверки Validate: административным командам не нужны
+// HTTP-сервер и ключи подписи токенов.
+type Scope int
+
+const (
+	ScopeServe Scope = iota
+	ScopeAdmin
+)
+
+var (
+	logLevels      = []string{"debug", "info", "warn", "error"}
+	logFormats     = []string{"console", "json"}
+	sameSites      = []string{"lax", "strict", "none"}
+	traceExporters = []string{"", "none", "stdout", "otlp"}
+)
+
+// Validate проверяет конфигурацию для scope и возвращает все найденные проблемы сразу.
+func (s Config) Validate(scope Scope) error {
+	errs := slices.Clone(s.errs)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(s.DB.MaxConns >= 0 && s.DB.MaxConns <= math.MaxInt32, "DB MAX CONNS MUST BE BETWEEN 0 AND %d", math.MaxInt32)
+	check(s.DB.MinConns >= 0, "DB MIN CONNS CAN'T BE NEGATIVE")
+	check(s.DB.MaxConns == 0 || s.DB.MinConns <= s.DB.MaxConns, "DB MIN CONNS CAN'T EXCEED MAX CONNS")
+	check(s.DB.ConnMaxLifetime >= 0, "DB CONN MAX LIFETIME CAN'T BE NEGATIVE")
+	check(s.DB.ConnMaxIdleTime >= 0, "DB CONN MAX IDLE TIME CAN'T BE NEGATIVE")
+
+	if s.Accrual.Address != "" {
+		u, err := url.Parse(s.Accrual.Address)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"ACCRUAL SYSTEM ADDRESS (-r, ACCRUAL_SYSTEM_ADDRESS) MUST BE http(s)://host:port, GOT %q", s.Accrual.Address)
+	}
+
+	check(s.Accrual.Workers > 0, "WORKERS MUST BE POSITIVE")
+	check(s.Accrual.PullInterval > 0, "PULL INTERVAL MUST BE POSITIVE")
+	check(s.Accrual.RateLimit >= 0, "ACCRUAL RATE LIMIT CAN'T BE NEGATIVE")
+
+	check(slices.Contains(logLevels, strings.ToLower(s.Log.Level)), "LOG LEVEL MUST BE ONE OF %v, GOT %q", logLevels, s.Log.Level)
+	check(slices.Contains(logFormats, strings.ToLower(s.Log.Format)), "LOG FORMAT MUST BE ONE OF %v, GOT %q", logFormats, s.Log.Format)
+	check(s.Log.Sampling >= 0, "LOG SAMPLING CAN'T BE NEGATIVE")
+
+	check(slices.Contains(traceExporters, strings.ToLower(s.Trace.Exporter)),
+		"TRACE EXPORTER MUST BE ONE OF %v, GOT %q", traceExporters[1:], s.Trace.Exporter)
+
+	check(s.Audit.Retention >= 0, "AUDIT RETENTION CAN'T BE NEGATIVE")
+
+	if scope == ScopeServe {
+		_, _, err := net.SplitHostPort(s.HTTP.Address)
+		check(err == nil, "RUN ADDRESS (-a, RUN_ADDRESS) MUST BE host:port, GOT %q", s.HTTP.Address)
+
+		if s.HTTP.MetricsAddress != "" {
+			_, _, err := net.SplitHostPort(s.HTTP.MetricsAddress)
+			check(err == nil, "METRICS ADDRESS (-metrics-address, METRICS_ADDRESS) MUST BE host:port, GOT %q", s.HTTP.MetricsAddress)
+			check(s.HTTP.MetricsAddress != s.HTTP.Address, "METRICS ADDRESS MUST DIFFER FROM RUN ADDRESS")
+		}
+
+		check(s.HTTP.ReadTimeout >= 0, "HTTP READ TIMEOUT CAN'T BE NEGATIVE")
+		check(s.HTTP.ReadHeaderTimeout >= 0, "HTTP READ HEADER TIMEOUT CAN'T BE NEGATIVE")
+		check(s.HTTP.WriteTimeout >= 0, "HTTP WRITE TIMEOUT CAN'T BE NEGATIVE")
+		check(s.HTTP.IdleTimeout >= 0, "HTTP IDLE TIMEOUT CAN'T BE NEGATIVE")
+		check(s.HTTP.ShutdownTimeout > 0, "SHUTDOWN TIMEOUT MUST BE POSITIVE")
+		check(s.HTTP.CompressMin >= 0, "COMPRESS MIN SIZE CAN'T BE NEGATIVE")
+		check(s.HTTP.CompressLevel >= gzip.HuffmanOnly && s.HTTP.CompressLevel <= gzip.BestCompression,
+			"COMPRESS LEVEL MUST BE BETWEEN %d AND %d", gzip.HuffmanOnly, gzip.BestCompression)
+		check(slices.Contains(sameSites, strings.ToLower(s.HTTP.Cookie.SameSite)),
+			"COOKIE SAMESITE MUST BE ONE OF %v, GOT %q", sameSites, s.HTTP.Cookie.SameSite)
+		check(!strings.EqualFold(s.HTTP.Cookie.SameSite, "none") || s.HTTP.Cookie.Secure,
+			"COOKIE SAMESITE=none REQUIRES COOKIE_SECURE")
+
+		check(s.JWT.Secret != "" || len(s.JWT.Keys) > 0, "JWT SECRET (-k, SECRET_KEY) OR KEYS (-jwt-keys, JWT_KEYS) REQUIRED")
+		check(s.JWT.Secret == "" || len(s.JWT.Secret) >= sec.MinSecretLen, "JWT SECRET MUST BE AT LEAST %d BYTES", sec.MinSecretLen)
+		check(s.JWT.TokenTTL > 0, "ACCESS TOKEN LIFETIME MUST BE POSITIVE")
+		check(s.JWT.RefreshTTL > 0, "REFRESH TOKEN LIFETIME MUST BE POSITIVE")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w:\n%w", ErrInvalidConfig, errors.Join(errs...))
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+)
+
+const (
+	DefAuditMaxBody       = 16 << 10
+	DefAuditPurgeInterval = time.Hour
+	auditSaveTimeout      = 5 * time.Second
+)
+
+type (
+	IAuditStorage interface {
+		SaveRequest(ctx context.Context, req models.Request) (models.Request, error)
+		PurgeRequests(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	// Auditor сохраняет тела запросов и ответов операций с деньгами в таблицу request,
+	// чтобы поддержка могла восстановить, что именно прислал клиент. Секреты маскируются,
+	// записи старше Retention периодически удаляются.
+	Auditor struct {
+		Log           logger.Lg
+		Service       IAuditStorage
+		Retention     time.Duration
+		PurgeInterval time.Duration
+		MaxBody       int
+		cancel        context.CancelFunc
+		wg            sync.WaitGroup
+	}
+
+	auditWriter struct {
+		*statusWriter
+		body  bytes.Buffer
+		limit int
+	}
+)
+
+func (a *Auditor) Handler(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		in, err := io.ReadAll(io.LimitReader(r.Body, int64(a.MaxBody)+1))
+
+		if err != nil {
+			writeError(w, r, ErrBadBody)
+			logger.FromContext(r.Context(), a.Log).Warnln("CAN'T READ BODY FOR AUDIT:", err)
+			return
+		}
+
+		// тело целиком возвращается обработчику: в аудит идёт только начало
+		r.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(in), r.Body), closers: []io.Closer{r.Body}}
+
+		aw := &auditWriter{statusWriter: &statusWriter{ResponseWriter: w}, limit: a.MaxBody}
+
+		next.ServeHTTP(aw, r)
+
+		if aw.status == 0 {
+			aw.status = http.StatusOK
+		}
+
+		personID, _ := r.Context().Value(contextParam("CurrPersonID")).(int)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), auditSaveTimeout)
+		defer cancel()
+
+		_, err = a.Service.SaveRequest(ctx, models.Request{
+			Pid:       uint(personID),
+			RequestID: requestID(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    aw.status,
+			Intext:    truncate(logger.Redact(string(in)), a.MaxBody),
+			Outtext:   truncate(logger.Redact(aw.body.String()), a.MaxBody),
+		})
+
+		if err != nil {
+			logger.FromContext(r.Context(), a.Log).Warnln("CAN'T SAVE REQUEST AUDIT:", err)
+		}
+	}
+
+	return http.HandlerFunc(f)
+}
+
+// StartPurge запускает периодическое удаление устаревших записей аудита.
+func (a *Auditor) StartPurge(ctx context.Context) {
+	if a.Retention <= 0 {
+		return
+	}
+
+	ctx, a.cancel = context.WithCancel(ctx)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			a.purge(ctx)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (a *Auditor) Stop(ctx context.Context) error {
+	if a.cancel == nil {
+		return nil
+	}
+
+	a.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Auditor) purge(ctx context.Context) {
+	n, err := a.Service.PurgeRequests(ctx, time.Now().Add(-a.Retention))
+
+	if err != nil {
+		if ctx.Err() == nil {
+			a.Log.Warnln("CAN'T PURGE REQUEST AUDIT:", err)
+		}
+		return
+	}
+
+	if n > 0 {
+		a.Log.Infoln("REQUEST AUDIT RECORDS PURGED:", n)
+	}
+}
+
+func (w *auditWriter) Write(p []byte) (int, error) {
+	if rest := w.limit + 1 - w.body.Len(); rest > 0 {
+		w.body.Write(p[:min(len(p), rest)])
+	}
+
+	return w.statusWriter.Write(p)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return strings.ToValidUTF8(s, "")
+	}
+
+	return strings.ToValidUTF8(s[:n], "") + "...[TRUNCATED]"
+}
+
+func NewAuditor(log logger.Lg, serv IAuditStorage, retention time.Duration) *Auditor {
+	return &Auditor{
+		Log:           log.Named(logger.NameAudit),
+		Service:       serv,
+		Retention:     retention,
+		PurgeInterval: DefAuditPurgeInterval,
+		MaxBody:       DefAuditMaxBody,
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuditStorage struct {
+	saved []models.Request
+}
+
+func (f *fakeAuditStorage) SaveRequest(_ context.Context, req models.Request) (models.Request, error) {
+	f.saved = append(f.saved, req)
+	return req, nil
+}
+
+func (f *fakeAuditStorage) PurgeRequests(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestAuditor_Handler(t *testing.T) {
+	store := &fakeAuditStorage{}
+	a := NewAuditor(logger.NewLg(), store, time.Hour)
+	a.MaxBody = 32
+
+	body := `{"order":"2377225624","sum":751,"password":"secret","comment":"` + strings.Repeat("x", 64) + `"}`
+
+	var got string
+
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got = string(b)
+		w.WriteHeader(http.StatusPaymentRequired)
+		_, _ = w.Write([]byte("RED SALDO"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), contextParam("CurrPersonID"), 7)
+	ctx = context.WithValue(ctx, contextParam("RequestID"), "req-1")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	assert.Equal(t, body, got, "handler must receive the whole body")
+
+	require.Len(t, store.saved, 1)
+	saved := store.saved[0]
+
+	assert.Equal(t, uint(7), saved.Pid)
+	assert.Equal(t, "req-1", saved.RequestID)
+	assert.Equal(t, http.StatusPaymentRequired, saved.Status)
+	assert.Equal(t, "RED SALDO", saved.Outtext)
+	assert.NotContains(t, saved.Intext, "secret")
+	assert.True(t, strings.HasSuffix(saved.Intext, "[TRUNCATED]"))
+}
+
+func TestSrv_actRequestID(t *testing.T) {
+	s := &Srv{Log: logger.NewLg()}
+
+	var seen string
+
+	h := s.actRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "client id is kept", header: "3f2b-41aa", keep: true},
+		{name: "missing id is generated"},
+		{name: "unsafe id is replaced", header: "bad id\nINJECT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, w.Header().Get(requestIDHeader))
+
+			if tt.keep {
+				assert.Equal(t, tt.header, seen)
+			} else {
+				assert.NotEqual(t, tt.header, seen)
+			}
+		})
+	}
+}
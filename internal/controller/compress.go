@@ -0,0 +1,287 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+var DefCompressTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"text/plain",
+	"text/html",
+}
+
+type (
+	// CompressConf — настройки сжатия ответов. Ответы короче MinSize и с типом
+	// вне Types отдаются как есть.
+	CompressConf struct {
+		MinSize int
+		Level   int
+		Types   []string
+	}
+
+	Compressor struct {
+		Conf     CompressConf
+		gzipPool sync.Pool
+	}
+
+	compressWriter struct {
+		http.ResponseWriter
+		c           *Compressor
+		encoding    string
+		status      int
+		buf         bytes.Buffer
+		enc         io.WriteCloser
+		decided     bool
+		wroteHeader bool
+	}
+
+	readCloser struct {
+		io.Reader
+		closers []io.Closer
+	}
+)
+
+func NewCompressor(conf CompressConf) *Compressor {
+	if conf.Level == 0 || conf.Level < gzip.HuffmanOnly || conf.Level > gzip.BestCompression {
+		conf.Level = gzip.DefaultCompression
+	}
+
+	if conf.Types == nil {
+		conf.Types = DefCompressTypes
+	}
+
+	c := &Compressor{Conf: conf}
+
+	c.gzipPool.New = func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, conf.Level)
+		return w
+	}
+
+	return c
+}
+
+// Handler распаковывает тело запроса с Content-Encoding gzip или deflate и сжимает
+// ответ, если клиент это допускает в Accept-Encoding.
+func (c *Compressor) Handler(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		if encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding != "" && encoding != "identity" {
+			body, err := decompress(encoding, r.Body)
+
+			if err != nil {
+				writeError(w, r, ErrUnsupportedEncode)
+				return
+			}
+
+			r.Body = body
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, c: c, encoding: encoding, status: http.StatusOK}
+
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	}
+
+	return http.HandlerFunc(f)
+}
+
+func decompress(encoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	switch encoding {
+	case encodingGzip, "x-gzip":
+		zr, err := gzip.NewReader(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &readCloser{Reader: zr, closers: []io.Closer{zr, body}}, nil
+	case encodingDeflate:
+		zr, err := zlib.NewReader(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &readCloser{Reader: zr, closers: []io.Closer{zr, body}}, nil
+	}
+
+	return nil, http.ErrNotSupported
+}
+
+// acceptedEncoding выбирает gzip или deflate из Accept-Encoding, учитывая q=0.
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+
+		accepted[name] = true
+	}
+
+	switch {
+	case accepted[encodingGzip], accepted["*"]:
+		return encodingGzip
+	case accepted[encodingDeflate]:
+		return encodingDeflate
+	}
+
+	return ""
+}
+
+func (c *Compressor) allowType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, t := range c.Conf.Types {
+		if t == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf.Write(p)
+
+	if cw.buf.Len() >= cw.c.Conf.MinSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// decide решает, сжимать ли ответ, отправляет заголовки и накопленный буфер.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+
+	header := cw.Header()
+
+	if header.Get("Content-Type") == "" && cw.buf.Len() > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+	}
+
+	header.Add("Vary", "Accept-Encoding")
+
+	compress := cw.buf.Len() >= cw.c.Conf.MinSize &&
+		header.Get("Content-Encoding") == "" &&
+		cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified &&
+		cw.c.allowType(header.Get("Content-Type"))
+
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		if cw.encoding == encodingGzip {
+			gz, _ := cw.c.gzipPool.Get().(*gzip.Writer)
+			gz.Reset(cw.ResponseWriter)
+			cw.enc = gz
+		} else {
+			zw, err := zlib.NewWriterLevel(cw.ResponseWriter, cw.c.Conf.Level)
+
+			if err != nil {
+				return err
+			}
+			cw.enc = zw
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+
+	cw.buf.Reset()
+
+	return err
+}
+
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			return
+		}
+		_ = cw.decide()
+	}
+
+	if cw.enc == nil {
+		return
+	}
+
+	_ = cw.enc.Close()
+
+	if gz, ok := cw.enc.(*gzip.Writer); ok {
+		cw.c.gzipPool.Put(gz)
+	}
+}
+
+func (rc *readCloser) Close() error {
+	var first error
+
+	for _, c := range rc.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressor_Handler(t *testing.T) {
+	big := `{"list":"` + strings.Repeat("a", 512) + `"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+	}{
+		{name: "gzip json", acceptEncoding: "gzip, deflate", contentType: "application/json", body: big, wantEncoding: "gzip"},
+		{name: "deflate json", acceptEncoding: "deflate", contentType: "application/json", body: big, wantEncoding: "deflate"},
+		{name: "gzip refused by q=0", acceptEncoding: "gzip;q=0, deflate", contentType: "application/json", body: big, wantEncoding: "deflate"},
+		{name: "no accept encoding", contentType: "application/json", body: big},
+		{name: "small body", acceptEncoding: "gzip", contentType: "application/json", body: `{}`},
+		{name: "type not allowed", acceptEncoding: "gzip", contentType: "image/png", body: big},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewCompressor(CompressConf{MinSize: 256}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, tt.wantEncoding, res.Header.Get("Content-Encoding"))
+
+			var reader io.Reader = res.Body
+
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(res.Body)
+				require.NoError(t, err)
+				reader = zr
+			case "deflate":
+				zr, err := zlib.NewReader(res.Body)
+				require.NoError(t, err)
+				reader = zr
+			}
+
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestCompressor_HandlerRequest(t *testing.T) {
+	var got string
+
+	h := NewCompressor(CompressConf{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte("12345678903"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "12345678903", got)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader("12345678903"))
+	req.Header.Set("Content-Encoding", "br")
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
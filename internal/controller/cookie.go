@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	tokenCookie       = "token"
+	refreshCookie     = "refresh_token"
+	refreshCookiePath = "/api/user"
+	bearerPrefix      = "Bearer "
+)
+
+var ErrNoAccessToken = errors.New("NO ACCESS TOKEN")
+
+// CookieConf задаёт атрибуты auth-cookie. Все cookie ставятся с HttpOnly.
+type CookieConf struct {
+	Secure   bool
+	SameSite http.SameSite
+	Path     string
+	Domain   string
+}
+
+func NewCookieConf(secure bool, sameSite, path, domain string) CookieConf {
+	c := CookieConf{
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+		Path:     path,
+		Domain:   domain,
+	}
+
+	switch strings.ToLower(sameSite) {
+	case "strict":
+		c.SameSite = http.SameSiteStrictMode
+	case "none":
+		// браузеры принимают SameSite=None только вместе с Secure
+		c.SameSite = http.SameSiteNoneMode
+		c.Secure = true
+	}
+
+	if c.Path == "" {
+		c.Path = "/"
+	}
+
+	return c
+}
+
+// New создаёт cookie со сроком жизни ttl. Отрицательный ttl удаляет cookie.
+func (c CookieConf) New(name, value string, ttl time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Secure:   c.Secure,
+		HttpOnly: true,
+		SameSite: c.SameSite,
+	}
+
+	if ttl < 0 {
+		cookie.MaxAge = -1
+		return cookie
+	}
+
+	cookie.MaxAge = int(ttl.Seconds())
+	cookie.Expires = time.Now().Add(ttl)
+
+	return cookie
+}
+
+// readAccessToken берёт токен из заголовка Authorization: Bearer, а при его отсутствии — из cookie.
+func readAccessToken(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if len(header) > len(bearerPrefix) && strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(header[len(bearerPrefix):]), nil
+		}
+		return "", ErrNoAccessToken
+	}
+
+	cookie, err := r.Cookie(tokenCookie)
+
+	if err != nil || cookie.Value == "" {
+		return "", ErrNoAccessToken
+	}
+
+	return cookie.Value, nil
+}
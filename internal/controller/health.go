@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+)
+
+const (
+	checkOK       = "ok"
+	checkFail     = "fail"
+	checkDegraded = "degraded"
+	checkDisabled = "disabled"
+
+	// errUnavailable заменяет в ответе текст ошибки: /readyz доступна без авторизации,
+	// а ошибки драйвера содержат хост, пользователя и имя БД. Подробности — в журнале.
+	errUnavailable = "unavailable"
+
+	defHealthTimeout = 2 * time.Second
+)
+
+type (
+	IHealthStorage interface {
+		Ping(ctx context.Context) error
+		PendingMigrations(ctx context.Context) (int, error)
+	}
+
+	IAccrualState interface {
+		State() accrual.State
+	}
+
+	// Health отвечает на проверки живости и готовности. БД и миграции обязательны
+	// для готовности, состояние системы начислений только отображается.
+	Health struct {
+		Log     logger.Lg
+		Storage IHealthStorage
+		Accrual IAccrualState
+		Timeout time.Duration
+	}
+)
+
+func (h *Health) actHealthz(w http.ResponseWriter, r *http.Request) {
+	h.write(w, http.StatusOK, HealthResponce{Status: checkOK})
+}
+
+func (h *Health) actReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.Timeout)
+	defer cancel()
+
+	res := HealthResponce{
+		Status: checkOK,
+		Checks: map[string]CheckResponce{
+			"db":         h.checkDB(ctx),
+			"migrations": h.checkMigrations(ctx),
+			"accrual":    h.checkAccrual(),
+		},
+	}
+
+	status := http.StatusOK
+
+	for _, name := range []string{"db", "migrations"} {
+		if res.Checks[name].Status != checkOK {
+			res.Status = checkFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	if res.Status == checkOK && res.Checks["accrual"].Status == checkDegraded {
+		res.Status = checkDegraded
+	}
+
+	h.write(w, status, res)
+}
+
+func (h *Health) checkDB(ctx context.Context) CheckResponce {
+	if err := h.Storage.Ping(ctx); err != nil {
+		h.Log.Warnln("READINESS: DB IS UNAVAILABLE:", err)
+		return CheckResponce{Status: checkFail, Error: errUnavailable}
+	}
+
+	return CheckResponce{Status: checkOK}
+}
+
+func (h *Health) checkMigrations(ctx context.Context) CheckResponce {
+	pending, err := h.Storage.PendingMigrations(ctx)
+
+	if err != nil {
+		h.Log.Warnln("READINESS: CAN'T CHECK MIGRATIONS:", err)
+		return CheckResponce{Status: checkFail, Error: errUnavailable}
+	}
+
+	if pending > 0 {
+		return CheckResponce{Status: checkFail, Pending: &pending}
+	}
+
+	return CheckResponce{Status: checkOK, Pending: &pending}
+}
+
+func (h *Health) checkAccrual() CheckResponce {
+	if h.Accrual == nil {
+		return CheckResponce{Status: checkDisabled}
+	}
+
+	state := h.Accrual.State()
+	res := CheckResponce{Status: checkOK, Limit: state.Limit}
+
+	if !state.LastSeen.IsZero() {
+		res.LastSeen = &state.LastSeen
+	}
+
+	if state.LastError != "" {
+		res.Status = checkDegraded
+		res.Error = errUnavailable
+	}
+
+	if state.PausedUntil.After(time.Now()) {
+		res.Status = checkDegraded
+		res.PausedUntil = &state.PausedUntil
+	}
+
+	return res
+}
+
+func (h *Health) write(w http.ResponseWriter, status int, res HealthResponce) {
+	output, err := json.Marshal(res)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		h.Log.Errorln("CAN'T MARSHAL HEALTH:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(output); err != nil {
+		h.Log.Warnln("CAN'T WRITE HEALTH:", err)
+	}
+}
+
+func NewHealth(log logger.Lg, storage IHealthStorage, accrualState IAccrualState) *Health {
+	return &Health{
+		Log:     log,
+		Storage: storage,
+		Accrual: accrualState,
+		Timeout: defHealthTimeout,
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHealthStorage struct {
+	pingErr    error
+	pending    int
+	pendingErr error
+}
+
+func (f fakeHealthStorage) Ping(context.Context) error {
+	return f.pingErr
+}
+
+func (f fakeHealthStorage) PendingMigrations(context.Context) (int, error) {
+	return f.pending, f.pendingErr
+}
+
+type fakeAccrualState accrual.State
+
+func (f fakeAccrualState) State() accrual.State {
+	return accrual.State(f)
+}
+
+func TestHealth_actReadyz(t *testing.T) {
+	tests := []struct {
+		name        string
+		storage     fakeHealthStorage
+		accrual     IAccrualState
+		wantCode    int
+		wantStatus  string
+		wantAccrual string
+	}{
+		{
+			name:        "All dependencies are ready",
+			accrual:     fakeAccrualState{LastSeen: time.Now()},
+			wantCode:    http.StatusOK,
+			wantStatus:  checkOK,
+			wantAccrual: checkOK,
+		},
+		{
+			name:        "Accrual is paused",
+			accrual:     fakeAccrualState{PausedUntil: time.Now().Add(time.Minute), Limit: 5},
+			wantCode:    http.StatusOK,
+			wantStatus:  checkDegraded,
+			wantAccrual: checkDegraded,
+		},
+		{
+			name:        "DB is down",
+			storage:     fakeHealthStorage{pingErr: errors.New("failed to connect to `user=app database=gophermart`")},
+			wantCode:    http.StatusServiceUnavailable,
+			wantStatus:  checkFail,
+			wantAccrual: checkDisabled,
+		},
+		{
+			name:        "Migrations can't be read",
+			storage:     fakeHealthStorage{pendingErr: errors.New("pq: relation goose_db_version")},
+			wantCode:    http.StatusServiceUnavailable,
+			wantStatus:  checkFail,
+			wantAccrual: checkDisabled,
+		},
+		{
+			name:        "Migrations are pending",
+			storage:     fakeHealthStorage{pending: 2},
+			wantCode:    http.StatusServiceUnavailable,
+			wantStatus:  checkFail,
+			wantAccrual: checkDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealth(logger.NewLg(), tt.storage, tt.accrual)
+
+			w := httptest.NewRecorder()
+			h.actReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", http.NoBody))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			res := HealthResponce{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, tt.wantStatus, res.Status)
+			assert.Equal(t, tt.wantAccrual, res.Checks["accrual"].Status)
+
+			for name, check := range res.Checks {
+				assert.Contains(t, []string{"", errUnavailable}, check.Error, "no raw error text in %s check", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDLen    = 16
+	maxRequestIDLen = 128
+)
+
+// actRequestID принимает X-Request-ID клиента или прокси, если он корректен, иначе
+// создаёт новый. Идентификатор возвращается в ответе и доступен через requestID.
+func (s *Srv) actRequestID(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(requestIDHeader, id)
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), contextParam("RequestID"), id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(f)
+}
+
+// actLogger кладёт в контекст дочерний логгер запроса и по завершении пишет строку
+// журнала доступа. person_id добавляет actMiddleWare после проверки токена, маршрут — lg.
+func (s *Srv) actLogger(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		fields := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_ip", remoteIP(r),
+		}
+
+		if id := requestID(r.Context()); id != "" {
+			fields = append(fields, "request_id", id)
+		}
+
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			fields = append(fields, "trace_id", sc.TraceID().String())
+		}
+
+		ctx := logger.WithContext(r.Context(), s.Log.With(fields...))
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		s.lg(ctx).Named(logger.NameAccess).Infow("ACCESS",
+			"status", sw.status,
+			"bytes", sw.bytes,
+			"duration", time.Since(start),
+			"user_agent", r.UserAgent(),
+		)
+	}
+
+	return http.HandlerFunc(f)
+}
+
+// lg возвращает логгер запроса с шаблоном маршрута chi, если он уже известен.
+func (s *Srv) lg(ctx context.Context) logger.Lg {
+	l := logger.FromContext(ctx, s.Log)
+
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		return l.With("route", rctx.RoutePattern())
+	}
+
+	return l
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextParam("RequestID")).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, requestIDLen)
+
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestID пропускает только короткие идентификаторы из безопасных символов,
+// чтобы чужой заголовок не ломал журнал.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
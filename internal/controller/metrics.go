@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+const unmatchedRoute = "unmatched"
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+// actMetrics учитывает запросы по шаблону маршрута chi, а не по URL, чтобы номера
+// заказов и прочие параметры не раздували число меток.
+func (s *Srv) actMetrics(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		route := unmatchedRoute
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		s.Metrics.ObserveHTTP(r.Method, route, sw.status, time.Since(start))
+	}
+
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+type observed struct {
+	method string
+	route  string
+	status int
+}
+
+type fakeMetrics struct {
+	requests    []observed
+	withdrawals []string
+}
+
+func (f *fakeMetrics) ObserveHTTP(method, route string, status int, _ time.Duration) {
+	f.requests = append(f.requests, observed{method: method, route: route, status: status})
+}
+
+func (f *fakeMetrics) AccrualPoll(string) {}
+
+func (f *fakeMetrics) Withdrawal(result string) {
+	f.withdrawals = append(f.withdrawals, result)
+}
+
+func TestSrv_actMetrics(t *testing.T) {
+	m := &fakeMetrics{}
+	s := &Srv{Log: logger.NewLg(), Metrics: m}
+
+	R := chi.NewRouter()
+	R.Use(s.actMetrics)
+	R.Get("/api/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, url := range []string{"/api/orders/12345678903", "/api/orders/79927398713", "/unknown"} {
+		R.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, http.NoBody))
+	}
+
+	assert.Equal(t, []observed{
+		{method: http.MethodGet, route: "/api/orders/{number}", status: http.StatusNoContent},
+		{method: http.MethodGet, route: "/api/orders/{number}", status: http.StatusNoContent},
+		{method: http.MethodGet, route: unmatchedRoute, status: http.StatusNotFound},
+	}, m.requests)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/DmitryM7/yapr56.git/internal/controller (interfaces: IQueueStorage)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/DmitryM7/yapr56.git/internal/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIQueueStorage is a mock of IQueueStorage interface.
+type MockIQueueStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockIQueueStorageMockRecorder
+}
+
+// MockIQueueStorageMockRecorder is the mock recorder for MockIQueueStorage.
+type MockIQueueStorageMockRecorder struct {
+	mock *MockIQueueStorage
+}
+
+// NewMockIQueueStorage creates a new mock instance.
+func NewMockIQueueStorage(ctrl *gomock.Controller) *MockIQueueStorage {
+	mock := &MockIQueueStorage{ctrl: ctrl}
+	mock.recorder = &MockIQueueStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIQueueStorage) EXPECT() *MockIQueueStorageMockRecorder {
+	return m.recorder
+}
+
+// GetOrdersByStatus mocks base method.
+func (m *MockIQueueStorage) GetOrdersByStatus(arg0 context.Context, arg1 int, arg2 ...string) ([]models.POrder, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrdersByStatus", varargs...)
+	ret0, _ := ret[0].([]models.POrder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersByStatus indicates an expected call of GetOrdersByStatus.
+func (mr *MockIQueueStorageMockRecorder) GetOrdersByStatus(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByStatus", reflect.TypeOf((*MockIQueueStorage)(nil).GetOrdersByStatus), varargs...)
+}
+
+// UpdateOrder mocks base method.
+func (m *MockIQueueStorage) UpdateOrder(arg0 context.Context, arg1 models.POrder) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrder", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrder indicates an expected call of UpdateOrder.
+func (mr *MockIQueueStorageMockRecorder) UpdateOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockIQueueStorage)(nil).UpdateOrder), arg0, arg1)
+}
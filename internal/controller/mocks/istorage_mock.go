@@ -1,14 +1,16 @@
 // Code generated by MockGen. DO NOT EDIT.
 // Source: github.com/DmitryM7/yapr56.git/internal/controller (interfaces: IStorage)
 
-// Package mock_controller is a generated GoMock package.
+// Package mocks is a generated GoMock package.
 package mocks
 
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/DmitryM7/yapr56.git/internal/models"
+	money "github.com/DmitryM7/yapr56.git/internal/money"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -65,11 +67,26 @@ func (mr *MockIStorageMockRecorder) CreatePeson(arg0, arg1 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePeson", reflect.TypeOf((*MockIStorage)(nil).CreatePeson), arg0, arg1)
 }
 
+// CreateWithdrawn mocks base method.
+func (m *MockIStorage) CreateWithdrawn(arg0 context.Context, arg1 models.Person, arg2 models.POrder, arg3 money.Money, arg4 string) (models.Opentry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWithdrawn", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(models.Opentry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWithdrawn indicates an expected call of CreateWithdrawn.
+func (mr *MockIStorageMockRecorder) CreateWithdrawn(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithdrawn", reflect.TypeOf((*MockIStorage)(nil).CreateWithdrawn), arg0, arg1, arg2, arg3, arg4)
+}
+
 // GetBalance mocks base method.
-func (m *MockIStorage) GetBalance(arg0 context.Context, arg1 models.Person) (int, error) {
+func (m *MockIStorage) GetBalance(arg0 context.Context, arg1 models.Person) (money.Money, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetBalance", arg0, arg1)
-	ret0, _ := ret[0].(int)
+	ret0, _ := ret[0].(money.Money)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -139,3 +156,106 @@ func (mr *MockIStorageMockRecorder) GetPesonByCredential(arg0, arg1, arg2 interf
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPesonByCredential", reflect.TypeOf((*MockIStorage)(nil).GetPesonByCredential), arg0, arg1, arg2)
 }
+
+// GetWithdrawals mocks base method.
+func (m *MockIStorage) GetWithdrawals(arg0 context.Context, arg1 models.Person) ([]models.Opentry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawals", arg0, arg1)
+	ret0, _ := ret[0].([]models.Opentry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawals indicates an expected call of GetWithdrawals.
+func (mr *MockIStorageMockRecorder) GetWithdrawals(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockIStorage)(nil).GetWithdrawals), arg0, arg1)
+}
+
+// Getwithdrawn mocks base method.
+func (m *MockIStorage) Getwithdrawn(arg0 context.Context, arg1 models.Person) (money.Money, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Getwithdrawn", arg0, arg1)
+	ret0, _ := ret[0].(money.Money)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Getwithdrawn indicates an expected call of Getwithdrawn.
+func (mr *MockIStorageMockRecorder) Getwithdrawn(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Getwithdrawn", reflect.TypeOf((*MockIStorage)(nil).Getwithdrawn), arg0, arg1)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockIStorage) IsTokenRevoked(arg0 context.Context, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockIStorageMockRecorder) IsTokenRevoked(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockIStorage)(nil).IsTokenRevoked), arg0, arg1)
+}
+
+// RevokeRefreshToken mocks base method.
+func (m *MockIStorage) RevokeRefreshToken(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshToken indicates an expected call of RevokeRefreshToken.
+func (mr *MockIStorageMockRecorder) RevokeRefreshToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshToken", reflect.TypeOf((*MockIStorage)(nil).RevokeRefreshToken), arg0, arg1)
+}
+
+// RevokeToken mocks base method.
+func (m *MockIStorage) RevokeToken(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockIStorageMockRecorder) RevokeToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockIStorage)(nil).RevokeToken), arg0, arg1, arg2)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockIStorage) RotateRefreshToken(arg0 context.Context, arg1 string, arg2 models.RefreshToken) (models.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockIStorageMockRecorder) RotateRefreshToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockIStorage)(nil).RotateRefreshToken), arg0, arg1, arg2)
+}
+
+// SaveRefreshToken mocks base method.
+func (m *MockIStorage) SaveRefreshToken(arg0 context.Context, arg1 models.RefreshToken) (models.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(models.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveRefreshToken indicates an expected call of SaveRefreshToken.
+func (mr *MockIStorageMockRecorder) SaveRefreshToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRefreshToken", reflect.TypeOf((*MockIStorage)(nil).SaveRefreshToken), arg0, arg1)
+}
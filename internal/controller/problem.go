@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/DmitryM7/yapr56.git/internal/service"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:gophermart:problem:"
+
+	CodeInternal = "INTERNAL_ERROR"
+)
+
+var (
+	ErrEmptyBody         = errors.New("EMPTY BODY")
+	ErrBadBody           = errors.New("CAN'T READ BODY")
+	ErrBadJSON           = errors.New("BAD JSON")
+	ErrBadOrderNumber    = errors.New("BAD ORDER NUMBER")
+	ErrUnauthorized      = errors.New("UNAUTHORIZED")
+	ErrPersonNotFound    = errors.New("PERSON NOT FOUND")
+	ErrUnsupportedEncode = errors.New("UNSUPPORTED CONTENT ENCODING")
+)
+
+type (
+	// Problem — тело ответа об ошибке по RFC 7807. Code стабилен и предназначен
+	// для программ, Title — для людей.
+	Problem struct {
+		Type      string       `json:"type"`
+		Title     string       `json:"title"`
+		Status    int          `json:"status"`
+		Code      string       `json:"code"`
+		Detail    string       `json:"detail,omitempty"`
+		Instance  string       `json:"instance,omitempty"`
+		RequestID string       `json:"request_id,omitempty"`
+		Errors    []FieldError `json:"errors,omitempty"`
+	}
+
+	problemKind struct {
+		err    error
+		status int
+		code   string
+		title  string
+	}
+)
+
+// problemKinds сопоставляет ошибки сервиса и контроллера с ответом. Порядок важен:
+// берётся первая ошибка, найденная errors.Is.
+var problemKinds = []problemKind{
+	{ErrEmptyBody, http.StatusBadRequest, "EMPTY_BODY", "Request body is empty"},
+	{ErrBadBody, http.StatusBadRequest, "BAD_BODY", "Request body can't be read"},
+	{ErrBadJSON, http.StatusBadRequest, "BAD_JSON", "Request body is not valid JSON"},
+	{ErrBadOrderNumber, http.StatusBadRequest, "BAD_ORDER_NUMBER", "Order number must contain digits only"},
+	{ErrUnauthorized, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required"},
+	{ErrNoAccessToken, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required"},
+	{ErrPersonNotFound, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required"},
+	{ErrUnsupportedMedia, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Content-Type is not supported"},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", "Request body is too large"},
+	{ErrValidation, http.StatusUnprocessableEntity, "VALIDATION_FAILED", "Request failed validation"},
+	{ErrUnsupportedEncode, http.StatusUnsupportedMediaType, "UNSUPPORTED_CONTENT_ENCODING", "Content-Encoding is not supported"},
+	{service.ErrUserExists, http.StatusConflict, "LOGIN_TAKEN", "Login is already taken"},
+	{service.ErrUserCredentialInvalid, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid login or password"},
+	{service.ErrUserBlocked, http.StatusForbidden, "USER_BLOCKED", "User is blocked"},
+	{service.ErrNoLuhnNumber, http.StatusUnprocessableEntity, "INVALID_ORDER_NUMBER", "Order number fails the Luhn check"},
+	{service.ErrOrderExists, http.StatusConflict, "ORDER_OF_ANOTHER_USER", "Order was uploaded by another user"},
+	{service.ErrRedSaldo, http.StatusPaymentRequired, "INSUFFICIENT_FUNDS", "Not enough points on the balance"},
+	{service.ErrIdempotencyKeyReuse, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was used for another request"},
+	{service.ErrRefreshTokenInvalid, http.StatusUnauthorized, "REFRESH_TOKEN_INVALID", "Refresh token is invalid or expired"},
+	{service.ErrRefreshTokenReused, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", "Refresh token was already used, session revoked"},
+}
+
+// badRequest — ошибки формата запроса, которые спецификация велит отдавать как 400
+// на маршрутах, где 413, 415 и 422 не предусмотрены.
+var badRequest = map[error]int{
+	ErrValidation:       http.StatusBadRequest,
+	ErrUnsupportedMedia: http.StatusBadRequest,
+	ErrBodyTooLarge:     http.StatusBadRequest,
+}
+
+// routeStatus уточняет статус из problemKinds для отдельных маршрутов: регистрация
+// и вход отвечают 400 на любой неверный запрос, а загрузка заказа — 422 на неверный
+// номер заказа.
+var routeStatus = map[string]map[error]int{
+	"/api/user/register": badRequest,
+	"/api/user/login":    badRequest,
+	"/api/user/orders": {
+		ErrBadOrderNumber:   http.StatusUnprocessableEntity,
+		ErrUnsupportedMedia: http.StatusBadRequest,
+		ErrBodyTooLarge:     http.StatusBadRequest,
+	},
+}
+
+// NewProblem строит ответ по ошибке. Неизвестные ошибки отдаются как 500 без
+// подробностей: текст внутренней ошибки клиенту не показывается.
+func NewProblem(r *http.Request, err error) Problem {
+	kind := problemKind{status: http.StatusInternalServerError, code: CodeInternal, title: "Internal server error"}
+
+	for _, k := range problemKinds {
+		if errors.Is(err, k.err) {
+			kind = k
+			break
+		}
+	}
+
+	if status, ok := routeStatus[r.URL.Path][kind.err]; ok {
+		kind.status = status
+	}
+
+	var fields ValidationError
+
+	errors.As(err, &fields)
+
+	return Problem{
+		Errors:    fields,
+		Type:      problemTypePrefix + strings.ToLower(kind.code),
+		Title:     kind.title,
+		Status:    kind.status,
+		Code:      kind.code,
+		Instance:  r.URL.Path,
+		RequestID: requestID(r.Context()),
+	}
+}
+
+// writeError отвечает application/problem+json по ошибке.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, NewProblem(r, err))
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	output, err := json.Marshal(p)
+
+	if err != nil {
+		w.WriteHeader(p.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(p.Status)
+
+	_, _ = w.Write(output)
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DmitryM7/yapr56.git/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"login taken", service.ErrUserExists, http.StatusConflict, "LOGIN_TAKEN"},
+		{"wrapped luhn", fmt.Errorf("CAN'T CREATE ORDER [%w]", service.ErrNoLuhnNumber), http.StatusUnprocessableEntity, "INVALID_ORDER_NUMBER"},
+		{"red saldo", service.ErrRedSaldo, http.StatusPaymentRequired, "INSUFFICIENT_FUNDS"},
+		{"blocked", service.ErrUserBlocked, http.StatusForbidden, "USER_BLOCKED"},
+		{"bad json", ErrBadJSON, http.StatusBadRequest, "BAD_JSON"},
+		{"no token", ErrNoAccessToken, http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"unknown", errors.New("PQ: CONNECTION REFUSED"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/user/orders", nil)
+			r = r.WithContext(context.WithValue(r.Context(), contextParam("RequestID"), "req-1"))
+
+			p := NewProblem(r, tt.err)
+
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.NotEmpty(t, p.Title)
+			assert.Equal(t, "/api/user/orders", p.Instance)
+			assert.Equal(t, "req-1", p.RequestID)
+			assert.NotContains(t, p.Title, "PQ")
+		})
+	}
+}
+
+func TestNewProblem_routeStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		err    error
+		status int
+	}{
+		{"register validation", "/api/user/register", ValidationError{{Field: "login", Message: "is required"}}, http.StatusBadRequest},
+		{"login media type", "/api/user/login", ErrUnsupportedMedia, http.StatusBadRequest},
+		{"order number", "/api/user/orders", ErrBadOrderNumber, http.StatusUnprocessableEntity},
+		{"withdraw validation", "/api/user/balance/withdraw", ValidationError{{Field: "order", Message: "is required"}}, http.StatusUnprocessableEntity},
+		{"conflict kept", "/api/user/register", service.ErrUserExists, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProblem(httptest.NewRequest(http.MethodPost, tt.path, nil), tt.err)
+
+			assert.Equal(t, tt.status, p.Status)
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	s := &Srv{}
+
+	h := s.actRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, ErrEmptyBody)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/user/register", bytes.NewReader(nil))
+	r.Header.Set(requestIDHeader, "abc-123")
+
+	h.ServeHTTP(w, r)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, problemContentType, res.Header.Get("Content-Type"))
+
+	p := Problem{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+
+	assert.Equal(t, "EMPTY_BODY", p.Code)
+	assert.Equal(t, problemTypePrefix+"empty_body", p.Type)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, "abc-123", p.RequestID)
+}
@@ -1,15 +1,329 @@
 package controller
 
-type Queue struct{}
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
-func (q *Queue) Pull() {
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/metrics"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/service"
+	"github.com/DmitryM7/yapr56.git/internal/tracing"
+)
 
+const (
+	pullBatchFactor = 10
+
+	pollNotRegistered = "not_registered"
+	pollUnchanged     = "unchanged"
+	pollThrottled     = "throttled"
+	pollError         = "error"
+)
+
+type (
+	IQueueStorage interface {
+		GetOrdersByStatus(ctx context.Context, limit int, statuses ...string) ([]models.POrder, error)
+		UpdateOrder(ctx context.Context, order models.POrder) error
+	}
+
+	IAccrualClient interface {
+		GetOrder(ctx context.Context, number string) (accrual.Order, error)
+	}
+
+	IAccrualMetrics interface {
+		AccrualPoll(outcome string)
+	}
+
+	Queue struct {
+		Log          logger.Lg
+		Service      IQueueStorage
+		Accrual      IAccrualClient
+		Workers      int
+		PullInterval time.Duration
+		Metrics      IAccrualMetrics
+		orders       chan models.POrder
+		inflight     sync.Map
+		wg           sync.WaitGroup
+		cancel       context.CancelFunc
+		abort        context.CancelFunc
+
+		// mu защищает Workers, PullInterval и список запущенных воркеров,
+		// которые меняются через Reconfigure
+		mu       sync.Mutex
+		ctx      context.Context
+		orderCtx context.Context
+		quits    []chan struct{}
+		stopped  bool
+		retick   chan struct{}
+	}
+)
+
+// Pull периодически выбирает из БД заказы в статусах NEW и PROCESSING
+// и передаёт их воркерам. Заказ, который ещё обрабатывается, повторно не отдаётся.
+func (q *Queue) Pull(ctx context.Context) {
+	defer close(q.orders)
+
+	workers, interval := q.settings()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		orders, err := q.Service.GetOrdersByStatus(ctx, workers*pullBatchFactor, service.StatusNew, service.StatusProcessing)
+
+		if err != nil && ctx.Err() == nil {
+			q.Log.Warnln("CAN'T PULL ORDERS FOR ACCRUAL:", err)
+		}
+
+		for _, order := range orders {
+			if _, busy := q.inflight.LoadOrStore(order.ID, struct{}{}); busy {
+				continue
+			}
+
+			select {
+			case q.orders <- order:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-q.retick:
+			// новый интервал применяется сразу, с внеочередной выборкой
+			_, interval = q.settings()
+			ticker.Reset(interval)
+		case <-ctx.Done():
+			return
+		}
+
+		workers, interval = q.settings()
+	}
+}
+
+func (q *Queue) settings() (int, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.Workers, q.PullInterval
+}
+
+// StartWorkers запускает выборку заказов и пул воркеров. Остановка — отменой ctx
+// или через Stop; заказ, взятый воркером, обрабатывается до конца.
+func (q *Queue) StartWorkers(ctx context.Context) {
+	// текущий заказ обрабатывается в своём контексте, который отменяется только
+	// по истечении срока остановки
+	orderCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	ctx, cancel := context.WithCancel(ctx)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.cancel, q.abort = cancel, abort
+	q.ctx, q.orderCtx = ctx, orderCtx
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		q.Pull(ctx)
+	}()
+
+	for len(q.quits) < q.Workers {
+		q.spawn()
+	}
+
+	go func() {
+		<-ctx.Done()
+		q.wg.Wait()
+		abort()
+	}()
+
+	q.Log.Infoln("ACCRUAL WORKERS STARTED:", q.Workers)
 }
 
-func (q *Queue) StartWorkers() {
+// Reconfigure меняет число воркеров и интервал выборки на ходу. Лишние воркеры
+// завершаются, доделав текущий заказ.
+func (q *Queue) Reconfigure(workers int, pullInterval time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if workers == q.Workers && pullInterval == q.PullInterval {
+		return
+	}
+
+	if pullInterval != q.PullInterval {
+		q.PullInterval = pullInterval
+
+		select {
+		case q.retick <- struct{}{}:
+		default:
+		}
+	}
+
+	q.Workers = workers
+
+	if q.ctx == nil || q.stopped {
+		return
+	}
+
+	for len(q.quits) < workers {
+		q.spawn()
+	}
+
+	for len(q.quits) > workers {
+		last := len(q.quits) - 1
+		close(q.quits[last])
+		q.quits = q.quits[:last]
+	}
 
+	q.Log.Infoln("ACCRUAL WORKERS RECONFIGURED:", workers, pullInterval)
 }
 
-func (q *Queue) send() {
+// spawn запускает ещё одного воркера. Вызывается под mu.
+func (q *Queue) spawn() {
+	quit := make(chan struct{})
+	q.quits = append(q.quits, quit)
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		q.work(q.ctx, q.orderCtx, quit)
+	}()
+}
+
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Stop прекращает выборку заказов и ждёт, пока воркеры закончат текущие. Если ctx
+// истёк раньше, обработка прерывается.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	cancel := q.cancel
+	q.stopped = true
+	q.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.abort()
+		<-done
+		return fmt.Errorf("ACCRUAL WORKERS WERE ABORTED [%w]", ctx.Err())
+	}
+}
+
+func (q *Queue) work(ctx, orderCtx context.Context, quit <-chan struct{}) {
+	for {
+		var order models.POrder
+
+		select {
+		case o, ok := <-q.orders:
+			if !ok {
+				return
+			}
+			order = o
+		case <-quit:
+			return
+		}
+
+		err := q.process(orderCtx, order)
+		q.inflight.Delete(order.ID)
+
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		if errors.Is(err, accrual.ErrTooManyRequests) {
+			q.Log.Warnln("ACCRUAL ASKS TO WAIT:", err)
+			continue
+		}
+
+		q.Log.Warnln(fmt.Sprintf("CAN'T PROCESS ORDER %s: %v", order.Extnum, err))
+	}
+}
+
+func (q *Queue) process(ctx context.Context, order models.POrder) error {
+	ctx, span := tracing.Start(ctx, "queue.process",
+		tracing.PersonID.Int(int(order.Pid)),
+		tracing.OrderNumber.String(order.Extnum))
+	defer span.End()
+
+	resp, err := q.Accrual.GetOrder(ctx, order.Extnum)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, accrual.ErrNotRegistered):
+			q.Metrics.AccrualPoll(pollNotRegistered)
+			return nil
+		case errors.Is(err, accrual.ErrTooManyRequests):
+			q.Metrics.AccrualPoll(pollThrottled)
+		default:
+			q.Metrics.AccrualPoll(pollError)
+		}
+		return err
+	}
+
+	newStatus := order.Status
+
+	switch resp.Status {
+	case accrual.StatusRegistered, accrual.StatusProcessing:
+		newStatus = service.StatusProcessing
+	case accrual.StatusInvalid:
+		newStatus = service.Invalid
+	case accrual.StatusProcessed:
+		newStatus = service.Processed
+	}
+
+	if newStatus == order.Status {
+		q.Metrics.AccrualPoll(pollUnchanged)
+		return nil
+	}
+
+	order.Status = newStatus
+
+	if resp.Accrual != nil {
+		order.Accrual = *resp.Accrual
+	}
+
+	if err := q.Service.UpdateOrder(ctx, order); err != nil {
+		q.Metrics.AccrualPoll(pollError)
+		return fmt.Errorf("CAN'T SAVE ORDER STATUS [%w]", err)
+	}
+
+	q.Metrics.AccrualPoll(strings.ToLower(order.Status))
+
+	q.Log.Debugln(fmt.Sprintf("ORDER %s MOVED TO %s", order.Extnum, order.Status))
+
+	return nil
+}
 
+func NewQueue(log logger.Lg, serv IQueueStorage, accrualClient IAccrualClient, workers int, pullInterval time.Duration) *Queue {
+	return &Queue{
+		Log:          log,
+		Service:      serv,
+		Accrual:      accrualClient,
+		Workers:      workers,
+		PullInterval: pullInterval,
+		Metrics:      metrics.Nop{},
+		orders:       make(chan models.POrder),
+		retick:       make(chan struct{}, 1),
+	}
 }
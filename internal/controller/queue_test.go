@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/accrual"
+	"github.com/DmitryM7/yapr56.git/internal/controller/mocks"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/DmitryM7/yapr56.git/internal/service"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAccrual map[string]accrual.Order
+
+func (f fakeAccrual) GetOrder(_ context.Context, number string) (accrual.Order, error) {
+	if number == "346436439" {
+		return accrual.Order{}, accrual.ErrTooManyRequests
+	}
+
+	order, ok := f[number]
+
+	if !ok {
+		return accrual.Order{}, accrual.ErrNotRegistered
+	}
+
+	return order, nil
+}
+
+func TestQueue_process(t *testing.T) {
+	logger := logger.NewLg()
+
+	points := money.Money(50050)
+
+	accrualClient := fakeAccrual{
+		"12345678903": {Order: "12345678903", Status: accrual.StatusProcessed, Accrual: &points},
+		"9278923470":  {Order: "9278923470", Status: accrual.StatusInvalid},
+	}
+
+	type want struct {
+		status  string
+		accrual money.Money
+		err     error
+	}
+
+	tests := []struct {
+		name  string
+		order models.POrder
+		want  want
+	}{
+		{
+			name:  "Processed order gets accrual",
+			order: models.POrder{ID: 1, Extnum: "12345678903", Status: service.StatusNew},
+			want:  want{status: service.Processed, accrual: 50050},
+		},
+		{
+			name:  "Invalid order",
+			order: models.POrder{ID: 2, Extnum: "9278923470", Status: service.StatusProcessing},
+			want:  want{status: service.Invalid},
+		},
+		{
+			name:  "Accrual asks to wait",
+			order: models.POrder{ID: 3, Extnum: "346436439", Status: service.StatusNew},
+			want:  want{err: accrual.ErrTooManyRequests},
+		},
+		{
+			name:  "Order isn't registered yet",
+			order: models.POrder{ID: 4, Extnum: "79927398713", Status: service.StatusNew},
+			want:  want{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			storage := mocks.NewMockIQueueStorage(ctrl)
+
+			if tt.want.status != "" {
+				storage.EXPECT().UpdateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o models.POrder) error {
+					assert.Equal(t, tt.want.status, o.Status)
+					assert.Equal(t, tt.want.accrual, o.Accrual)
+					return nil
+				})
+			}
+
+			q := NewQueue(logger, storage, accrualClient, 1, time.Second)
+
+			err := q.process(context.Background(), tt.order)
+
+			assert.ErrorIs(t, err, tt.want.err)
+		})
+	}
+}
+
+func TestQueue_Reconfigure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage := mocks.NewMockIQueueStorage(ctrl)
+	storage.EXPECT().GetOrdersByStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	q := NewQueue(logger.NewLg(), storage, fakeAccrual{}, 1, time.Hour)
+
+	q.Reconfigure(2, time.Hour)
+	assert.Empty(t, q.quits, "workers are not started before StartWorkers")
+
+	q.StartWorkers(context.Background())
+	assert.Len(t, q.quits, 2)
+
+	q.Reconfigure(4, time.Minute)
+	assert.Len(t, q.quits, 4)
+
+	q.Reconfigure(1, time.Minute)
+	assert.Len(t, q.quits, 1)
+
+	workers, interval := q.settings()
+	assert.Equal(t, 1, workers)
+	assert.Equal(t, time.Minute, interval)
+
+	assert.NoError(t, q.Stop(context.Background()))
+
+	q.Reconfigure(3, time.Minute)
+	assert.Len(t, q.quits, 1, "stopped queue doesn't spawn workers")
+}
@@ -1,6 +1,10 @@
 package controller
 
-import "time"
+import (
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/money"
+)
 
 type (
 	UserRegisterRequest struct {
@@ -12,19 +16,46 @@ type (
 		UserRegisterRequest
 	}
 
+	TokenRefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	TokenResponce struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
 	BalanceResponce struct {
-		Current   float32 `json:"current"`
-		Withdrawn float32 `json:"withdrawn"`
+		Current   money.Money `json:"current"`
+		Withdrawn money.Money `json:"withdrawn"`
 	}
 
 	WithdrawRequest struct {
-		Order string `json:"order"`
-		Sum   int    `json:"sum"`
+		Order string      `json:"order"`
+		Sum   money.Money `json:"sum"`
 	}
 
 	WithdrawalsResponce struct {
-		Order       int       `json:"order"`
-		Sum         int       `json:"sum"`
-		ProcessedAt time.Time `json:"processed_at"`
+		Order       string      `json:"order"`
+		Sum         money.Money `json:"sum"`
+		ProcessedAt time.Time   `json:"processed_at"`
+	}
+)
+
+type (
+	CheckResponce struct {
+		Status      string     `json:"status"`
+		Error       string     `json:"error,omitempty"`
+		Pending     *int       `json:"pending,omitempty"`
+		PausedUntil *time.Time `json:"paused_until,omitempty"`
+		Limit       int        `json:"limit,omitempty"`
+		LastSeen    *time.Time `json:"last_seen,omitempty"`
+	}
+
+	HealthResponce struct {
+		Status string                   `json:"status"`
+		Checks map[string]CheckResponce `json:"checks,omitempty"`
 	}
 )
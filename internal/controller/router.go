@@ -1,11 +1,23 @@
 package controller
 
 import (
+	"net/http"
+
 	"github.com/DmitryM7/yapr56.git/internal/logger"
 	"github.com/go-chi/chi"
 )
 
-func NewRouter(log logger.Lg, serv IStorage, jwt IJwtService) *chi.Mux {
+// RouterConf — настройки и необязательные подсистемы роутера.
+type RouterConf struct {
+	Cookie   CookieConf
+	Compress CompressConf
+	Health   *Health
+	Metrics  IMetrics
+	// Audit сохраняет запросы операций с деньгами, если задан.
+	Audit *Auditor
+}
+
+func NewRouter(log logger.Lg, serv IStorage, jwt IJwtService, conf RouterConf) *chi.Mux {
 	R := chi.NewRouter()
 	server, err := NewServer(log, serv, jwt)
 
@@ -13,15 +25,43 @@ func NewRouter(log logger.Lg, serv IStorage, jwt IJwtService) *chi.Mux {
 		log.Panicln("CAN'T CREATE SERVER")
 	}
 
+	server.Cookie = conf.Cookie
+
+	if conf.Metrics != nil {
+		server.Metrics = conf.Metrics
+	}
+
+	R.Use(server.actRequestID)
+	R.Use(server.actTrace)
+	R.Use(server.actMetrics)
+	R.Use(server.actLogger)
+	R.Use(NewCompressor(conf.Compress).Handler)
 	R.Use(server.actMiddleWare)
+
+	audit := func(h http.HandlerFunc) http.Handler {
+		if conf.Audit == nil {
+			return h
+		}
+		return conf.Audit.Handler(h)
+	}
+
 	R.Route("/", func(r chi.Router) {
+		R.Get("/.well-known/jwks.json", server.actJWKS)
+
+		if conf.Health != nil {
+			R.Get("/healthz", conf.Health.actHealthz)
+			R.Get("/readyz", conf.Health.actReadyz)
+		}
+
 		R.Route("/api/user", func(r chi.Router) {
 			r.Post("/register", server.actUserRegister)
 			r.Post("/login", server.actUserLogin)
-			r.Post("/orders", server.actOrdersUpload)
+			r.Post("/logout", server.actUserLogout)
+			r.Post("/token/refresh", server.actTokenRefresh)
+			r.Method(http.MethodPost, "/orders", audit(server.actOrdersUpload))
 			r.Get("/orders", server.actOrders)
 			r.Get("/balance", server.actAcctBalance)
-			r.Post("/balance/withdraw", server.actWithdraw)
+			r.Method(http.MethodPost, "/balance/withdraw", audit(server.actWithdraw))
 			r.Get("/withdrawls", server.actAcctStatement)
 		})
 	})
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/DmitryM7/yapr56.git/internal/sec"
+	"github.com/DmitryM7/yapr56.git/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewRouter_memStorage проходит весь сценарий клиента через роутер
+// на хранилище в памяти, без моков и PostgreSQL.
+func TestNewRouter_memStorage(t *testing.T) {
+	storage, err := service.NewMemStorage(logger.NewLg())
+	require.NoError(t, err)
+
+	router := NewRouter(logger.NewLg(), storage, sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t)), RouterConf{})
+
+	do := func(method, path, contentType, body, token string) *http.Response {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	res := do(http.MethodPost, "/api/user/register", jsonContentType, `{"login":"dmaslov","password":"!QAZ2wsx"}`, "")
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	tokens := TokenResponce{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&tokens))
+	token := tokens.AccessToken
+
+	res = do(http.MethodPost, "/api/user/register", jsonContentType, `{"login":"dmaslov","password":"!QAZ2wsx"}`, "")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusConflict, res.StatusCode, "login is unique")
+
+	res = do(http.MethodGet, "/metrics", "", "", "")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "metrics are not public")
+
+	res = do(http.MethodGet, "/metrics", "", "", token)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "metrics live on separate listener")
+
+	res = do(http.MethodPost, "/api/user/orders", textContentType, "12345678903", token)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	res = do(http.MethodPost, "/api/user/orders", textContentType, "12345678903", token)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "same order from same person")
+
+	orders, err := storage.GetOrdersByStatus(context.Background(), 10, service.StatusNew)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	orders[0].Status = service.Processed
+	orders[0].Accrual = money.Money(50050)
+	require.NoError(t, storage.UpdateOrder(context.Background(), orders[0]))
+
+	res = do(http.MethodPost, "/api/user/balance/withdraw", jsonContentType, `{"order":"2377225624","sum":100.25}`, token)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = do(http.MethodPost, "/api/user/balance/withdraw", jsonContentType, `{"order":"2377225624","sum":1000}`, token)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusPaymentRequired, res.StatusCode)
+
+	res = do(http.MethodGet, "/api/user/balance", "", "", token)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	balance := BalanceResponce{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&balance))
+	assert.Equal(t, money.Money(40025), balance.Current)
+	assert.Equal(t, money.Money(10025), balance.Withdrawn)
+
+	res = do(http.MethodGet, "/api/user/orders", "", "", token)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	list := []models.POrder{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&list))
+	require.Len(t, list, 1)
+	assert.Equal(t, service.Processed, list[0].Status)
+	assert.Equal(t, money.Money(50050), list[0].Accrual)
+
+	res = do(http.MethodGet, "/api/user/withdrawls", "", "", token)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	withdrawals := []WithdrawalsResponce{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&withdrawals))
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, "2377225624", withdrawals[0].Order)
+}
@@ -5,34 +5,69 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/metrics"
 	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/DmitryM7/yapr56.git/internal/sec"
 	"github.com/DmitryM7/yapr56.git/internal/service"
+	"github.com/DmitryM7/yapr56.git/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type (
 	IJwtService interface {
 		GetJwtStr(uid int) (string, error)
-		UnloadUserIDJwt(tokenString string) (int, error)
+		ParseJwt(tokenString string) (sec.Claims, error)
 		TokenExpired() time.Duration
+		RefreshExpired() time.Duration
+		NewRefreshToken() (token, hash string, err error)
+		HashRefreshToken(token string) string
+		JWKS() sec.JWKSet
 	}
 
-	IStorage interface {
+	// IPersonRepo — клиенты и их учётные данные.
+	IPersonRepo interface {
 		GetPesonByCredential(ctx context.Context, login, pass string) (models.Person, error)
 		CreatePeson(ctx context.Context, p models.Person) (models.Person, error)
+		GetPersonByID(ctx context.Context, id int) (models.Person, error)
+	}
+
+	// IOrderRepo — заказы, загруженные клиентами.
+	IOrderRepo interface {
 		CreateOrder(ctx context.Context, p models.Person, order models.POrder) (models.POrder, error)
 		GetOrder(ctx context.Context, order models.POrder) (models.POrder, error)
-		GetPersonByID(ctx context.Context, id int) (models.Person, error)
 		GetOrders(ctx context.Context, p models.Person) ([]models.POrder, error)
-		GetBalance(ctx context.Context, p models.Person) (int, error)
-		Getwithdrawn(ctx context.Context, p models.Person) (int, error)
+	}
+
+	// ILedgerRepo — счета клиентов: остаток, списания и их история.
+	ILedgerRepo interface {
+		GetBalance(ctx context.Context, p models.Person) (money.Money, error)
+		Getwithdrawn(ctx context.Context, p models.Person) (money.Money, error)
 		GetWithdrawals(ctx context.Context, p models.Person) ([]models.Opentry, error)
-		CreateWithdrawn(ctx context.Context, p models.Person, o models.POrder, sum int) (models.Opentry, error)
+		CreateWithdrawn(ctx context.Context, p models.Person, o models.POrder, sum money.Money, idemKey string) (models.Opentry, error)
+	}
+
+	// ITokenRepo — refresh-токены и отозванные access-токены.
+	ITokenRepo interface {
+		SaveRefreshToken(ctx context.Context, t models.RefreshToken) (models.RefreshToken, error)
+		RotateRefreshToken(ctx context.Context, oldHash string, next models.RefreshToken) (models.RefreshToken, error)
+		RevokeRefreshToken(ctx context.Context, hash string) error
+		RevokeToken(ctx context.Context, jti string, expires time.Time) error
+		IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	}
+
+	// IStorage объединяет репозитории, нужные обработчикам. Реализации —
+	// service.StorageService (PostgreSQL) и service.MemStorage (в памяти).
+	IStorage interface {
+		IPersonRepo
+		IOrderRepo
+		ILedgerRepo
+		ITokenRepo
 	}
 
 	Srv struct {
@@ -40,6 +75,15 @@ type (
 		Service       IStorage
 		JwtService    IJwtService
 		NoAuthActions map[string]string
+		Cookie        CookieConf
+		Metrics       IMetrics
+		MaxBody       int64
+	}
+
+	IMetrics interface {
+		ObserveHTTP(method, route string, status int, d time.Duration)
+		AccrualPoll(outcome string)
+		Withdrawal(result string)
 	}
 
 	contextParam string
@@ -50,63 +94,72 @@ func (s *Srv) actMiddleWare(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
-		s.Log.Debugln("URL PATH IS:", r.URL.Path)
+		s.lg(ctx).Debugln("URL PATH IS:", r.URL.Path)
 
 		if _, e := s.NoAuthActions[r.URL.Path]; !e {
-			cookie, err := r.Cookie("token")
+			token, err := readAccessToken(r)
 
 			if err != nil {
-				s.Log.Debugln("CAN'T READ COOKIE:", err)
-				w.WriteHeader(http.StatusUnauthorized)
+				s.lg(ctx).Debugln("CAN'T READ TOKEN:", err)
+				writeError(w, r, err)
 				return
 			}
 
-			personId, err := s.JwtService.UnloadUserIDJwt(cookie.Value)
+			claims, err := s.JwtService.ParseJwt(token)
 
 			if err != nil {
-				s.Log.Debugln("CAN'T UNLOAD ID FROM JWT:", err)
-				w.WriteHeader(http.StatusUnauthorized)
+				s.lg(ctx).Debugln("CAN'T UNLOAD ID FROM JWT:", err)
+				writeError(w, r, ErrUnauthorized)
 				return
 			}
 
-			ctx = context.WithValue(ctx, contextParam("CurrPersonID"), personId)
+			revoked, err := s.Service.IsTokenRevoked(ctx, claims.ID)
 
-		}
+			if err != nil {
+				s.lg(ctx).Warnln("CAN'T CHECK TOKEN REVOCATION:", err)
+				writeError(w, r, err)
+				return
+			}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	}
+			if revoked {
+				s.lg(ctx).Debugln("TOKEN IS REVOKED:", claims.ID)
+				writeError(w, r, ErrUnauthorized)
+				return
+			}
 
-	return http.HandlerFunc(f)
-}
-func (s *Srv) actUserRegister(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+			// статус проверяется на каждом запросе: заблокированный клиент теряет доступ
+			// сразу, а не по истечении access-токена
+			if _, err := s.Service.GetPersonByID(ctx, claims.UserID); err != nil {
+				if errors.Is(err, service.ErrUserBlocked) {
+					s.lg(ctx).Infoln("PERSON IS BLOCKED:", claims.UserID)
+					writeError(w, r, err)
+					return
+				}
+
+				s.lg(ctx).Warnln("CAN'T FIND PERSON WITH ID=", claims.UserID, err)
+				writeError(w, r, ErrPersonNotFound)
+				return
+			}
 
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T READ BODY:", err)
-		return
-	}
+			trace.SpanFromContext(ctx).SetAttributes(tracing.PersonID.Int(claims.UserID))
+
+			ctx = logger.WithContext(ctx, s.lg(ctx).With("person_id", claims.UserID))
+			ctx = context.WithValue(ctx, contextParam("CurrPersonID"), claims.UserID)
+			ctx = context.WithValue(ctx, contextParam("CurrTokenClaims"), claims)
 
-	defer func() {
-		err := r.Body.Close()
-		if err != nil {
-			s.Log.Warnln("CAN'T CLOSE BODY")
 		}
-	}()
 
-	if string(body) == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.Log.Debugln("EMPTY BODY")
-		return
+		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 
+	return http.HandlerFunc(f)
+}
+func (s *Srv) actUserRegister(w http.ResponseWriter, r *http.Request) {
 	request := UserRegisterRequest{}
 
-	err = json.Unmarshal(body, &request)
-
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.Log.Warnln("CAN'T UNMARSHAL USER REQUEST IN REGISTER ACTION:", err)
+	if err := s.decodeJSON(w, r, &request); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Infoln("INVALID REGISTER REQUEST:", err)
 		return
 	}
 
@@ -115,123 +168,80 @@ func (s *Srv) actUserRegister(w http.ResponseWriter, r *http.Request) {
 		Pass:  request.Password,
 	}
 
-	ctx := context.TODO()
+	ctx := r.Context()
 
-	person, err = s.Service.CreatePeson(ctx, person)
+	person, err := s.Service.CreatePeson(ctx, person)
 
 	if err != nil {
+		writeError(w, r, err)
+
 		if errors.Is(err, service.ErrUserExists) {
-			w.WriteHeader(http.StatusConflict)
-			s.Log.Debugln(fmt.Sprintf("LOGIN [%s] IS BUSY", person.Login))
+			s.lg(r.Context()).Debugln(fmt.Sprintf("LOGIN [%s] IS BUSY", request.Login))
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T CREATE PERSON BY CREDENTIAL:", err)
+		s.lg(r.Context()).Warnln("CAN'T CREATE PERSON BY CREDENTIAL:", err)
 		return
 	}
 
-	jwtToken, err := s.JwtService.GetJwtStr(int(person.ID))
+	s.lg(r.Context()).Debugln(fmt.Sprintf("PERSON WAS CREATE id=%d,login=%s", person.ID, person.Login))
 
-	if err != nil {
-		s.Log.Errorln("CAN'T CREATE JWT FOR USER:", person.ID)
-		return
+	if err := s.startSession(ctx, w, person.ID); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T CREATE SESSION FOR USER:", person.ID, err)
 	}
-
-	s.Log.Debugln(fmt.Sprintf("PERSON WAS CREATE id=%d,login=%s", person.ID, person.Login))
-
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   jwtToken,
-		Expires: time.Now().Add(s.JwtService.TokenExpired() * time.Minute),
-	})
-
-	w.WriteHeader(http.StatusOK)
 }
 func (s *Srv) actUserLogin(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T READ BODY")
-		return
-	}
-
-	defer func() {
-		err := r.Body.Close()
-		if err != nil {
-			s.Log.Warnln("CAN'T CLOSE BODY")
-		}
-	}()
-
 	p := UserAuthRequest{}
 
-	err = json.Unmarshal(body, &p)
-
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.Log.Errorln("CAN'T UNMARSHAL BODY:<" + string(body) + "<")
+	if err := s.decodeJSON(w, r, &p); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Infoln("INVALID LOGIN REQUEST:", err)
 		return
 	}
 
-	ctx := context.TODO()
+	ctx := r.Context()
 	person, err := s.Service.GetPesonByCredential(ctx, p.Login, p.Password)
 
 	if err != nil {
+		writeError(w, r, err)
+
 		if errors.Is(err, service.ErrUserCredentialInvalid) {
-			w.WriteHeader(http.StatusUnauthorized)
-			s.Log.Infoln("INVALID USER NAME OR PASS:", p.Login)
+			s.lg(r.Context()).Infoln("INVALID USER NAME OR PASS:", p.Login)
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T GET USER BY LOGIN AND PASS:", err)
-		return
-	}
-
-	jwtToken, err := s.JwtService.GetJwtStr(int(person.ID))
+		if errors.Is(err, service.ErrUserBlocked) {
+			s.lg(r.Context()).Infoln("BLOCKED USER TRIED TO LOGIN:", p.Login)
+			return
+		}
 
-	if err != nil {
-		s.Log.Errorln("CAN'T CREATE JWT FOR USER:", person.ID)
+		s.lg(r.Context()).Errorln("CAN'T GET USER BY LOGIN AND PASS:", err)
 		return
 	}
 
-	s.Log.Infoln("NOW PERSON IS ", person.ID)
-
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   jwtToken,
-		Expires: time.Now().Add(s.JwtService.TokenExpired() * time.Minute),
-	})
+	s.lg(r.Context()).Infoln("NOW PERSON IS ", person.ID)
 
-	w.WriteHeader(http.StatusOK)
+	if err := s.startSession(ctx, w, person.ID); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T CREATE SESSION FOR USER:", person.ID, err)
+	}
 }
 func (s *Srv) actOrdersUpload(w http.ResponseWriter, r *http.Request) {
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T READ BODY")
-		return
-	}
-
-	defer func() {
-		err := r.Body.Close()
-		if err != nil {
-			s.Log.Warnln("CAN'T CLOSE BODY")
-		}
-	}()
+	body, err := s.readBody(w, r, textContentType)
 
-	if string(body) == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.Log.Infoln("EMPTY BODY")
+	if err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Infoln("INVALID ORDER UPLOAD:", err)
 		return
 	}
 
-	extNum, err := strconv.Atoi(string(body))
+	number := strings.TrimSpace(string(body))
 
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.Log.Infoln("CAN'T PARSE ORDER NUMBER TO INT")
+	if !validOrderNumber(number) {
+		writeError(w, r, ErrBadOrderNumber)
+		s.lg(r.Context()).Infoln("ORDER NUMBER IS NOT A DIGIT STRING")
 		return
 	}
 
@@ -239,43 +249,37 @@ func (s *Srv) actOrdersUpload(w http.ResponseWriter, r *http.Request) {
 
 	if currPersonId, ok := ctx.Value(contextParam("CurrPersonID")).(int); ok {
 
-		s.Log.Infoln("CurrPerson is ", currPersonId)
+		s.lg(r.Context()).Infoln("CurrPerson is ", currPersonId)
 		currPerson, err := s.Service.GetPersonByID(ctx, currPersonId)
 
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			s.Log.Infoln("CAN'T FIND PERSON WITH ID=", currPersonId)
+			writeError(w, r, ErrPersonNotFound)
+			s.lg(r.Context()).Infoln("CAN'T FIND PERSON WITH ID=", currPersonId)
 			return
 		}
 
 		order := models.POrder{
-			Extnum: extNum,
+			Extnum: number,
 		}
 
 		order, err = s.Service.CreateOrder(ctx, currPerson, order)
 
 		if err != nil {
 
-			if errors.Is(err, service.ErrNoLuhnNumber) {
-				w.WriteHeader(http.StatusUnprocessableEntity)
-				s.Log.Errorln("INCORRECT ORDER NUMBER:", err)
-				return
-			}
-
 			if errors.Is(err, service.ErrDublicateOrder) {
 				w.WriteHeader(http.StatusOK)
-				s.Log.Infoln(err)
+				s.lg(r.Context()).Infoln(err)
 				return
 			}
 
-			if errors.Is(err, service.ErrOrderExists) {
-				w.WriteHeader(http.StatusConflict)
-				s.Log.Infoln(err)
+			writeError(w, r, err)
+
+			if errors.Is(err, service.ErrNoLuhnNumber) || errors.Is(err, service.ErrOrderExists) {
+				s.lg(r.Context()).Infoln("CAN'T ACCEPT ORDER:", err)
 				return
 			}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			s.Log.Errorln(err)
+			s.lg(r.Context()).Errorln("CAN'T CREATE ORDER:", err)
 			return
 
 		}
@@ -297,32 +301,32 @@ func (s *Srv) actOrders(w http.ResponseWriter, r *http.Request) {
 		orders, err := s.Service.GetOrders(ctx, person)
 
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			s.Log.Warnln("CAN'T GET ORDER LIST:", err)
+			writeError(w, r, err)
+			s.lg(r.Context()).Warnln("CAN'T GET ORDER LIST:", err)
 			return
 		}
 
 		if len(orders) == 0 {
 			w.WriteHeader(http.StatusNoContent)
-			s.Log.Infoln("ORDER LIST EMPTY")
+			s.lg(r.Context()).Infoln("ORDER LIST EMPTY")
 			return
 		}
 
 		result, err := json.Marshal(orders)
 
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			s.Log.Warnln("CAN'T MARSHAL ORDER LIST", err)
+			writeError(w, r, err)
+			s.lg(r.Context()).Warnln("CAN'T MARSHAL ORDER LIST:", err)
 			return
 		}
 
-		s.Log.Infoln(orders)
+		s.lg(r.Context()).Debugw("ORDER LIST", "count", len(orders))
 
 		w.WriteHeader(http.StatusOK)
 		_, err = w.Write(result)
 
 		if err != nil {
-			s.Log.Warnln("CAN'T WRITE BODY IN ORDER LIST", err)
+			s.lg(r.Context()).Warnln("CAN'T WRITE BODY IN ORDER LIST", err)
 			return
 		}
 
@@ -337,51 +341,52 @@ func (s *Srv) actAcctBalance(w http.ResponseWriter, r *http.Request) {
 	currPersonId, ok := ctx.Value(contextParam("CurrPersonID")).(int)
 
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		s.Log.Warnln("INVALID PERSON ID")
+		writeError(w, r, ErrUnauthorized)
+		s.lg(r.Context()).Warnln("INVALID PERSON ID")
 		return
 	}
 
 	person, err := s.Service.GetPersonByID(ctx, currPersonId)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		s.Log.Warnln("INVALID PERSON ID")
+		writeError(w, r, ErrPersonNotFound)
+		s.lg(r.Context()).Warnln("CAN'T FIND PERSON WITH ID=", currPersonId, err)
+		return
 	}
 
 	balance, err := s.Service.GetBalance(ctx, person)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T GET BALANCE BY PERSON:", err)
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T GET BALANCE BY PERSON:", err)
 		return
 	}
 
 	withdrawn, err := s.Service.Getwithdrawn(ctx, person)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T WITHDRAWN BY PERSON:", err)
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T WITHDRAWN BY PERSON:", err)
 		return
 	}
 
 	output, err := json.Marshal(BalanceResponce{
-		Current:   float32(balance),
-		Withdrawn: float32(withdrawn),
+		Current:   balance,
+		Withdrawn: withdrawn,
 	})
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T MARSHAL DATA:[%v]", err)
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T MARSHAL DATA:", err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_, err = w.Write(output)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T WRITE DATA TO BODY:[%v]", err)
+		s.lg(r.Context()).Errorln("CAN'T WRITE DATA TO BODY:", err)
 		return
 	}
 
@@ -394,76 +399,61 @@ func (s *Srv) actWithdraw(w http.ResponseWriter, r *http.Request) {
 	currPersonId, ok := ctx.Value(contextParam("CurrPersonID")).(int)
 
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		s.Log.Warnln("INVALID PERSON ID")
+		writeError(w, r, ErrUnauthorized)
+		s.lg(r.Context()).Warnln("INVALID PERSON ID")
 		return
 	}
 
 	person, err := s.Service.GetPersonByID(ctx, currPersonId)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		s.Log.Warnln("INVALID PERSON ID")
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T READ BODY")
+		writeError(w, r, ErrPersonNotFound)
+		s.lg(r.Context()).Warnln("CAN'T FIND PERSON WITH ID=", currPersonId, err)
 		return
 	}
 
-	defer func() {
-		err := r.Body.Close()
-		if err != nil {
-			s.Log.Warnln("CAN'T CLOSE BODY")
-		}
-	}()
-
 	input := WithdrawRequest{}
 
-	err = json.Unmarshal(body, &input)
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T UNMARSHAL BODY:", err)
+	if err := s.decodeJSON(w, r, &input); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Infoln("INVALID WITHDRAW REQUEST:", err)
 		return
 	}
 
-	extnum, err := strconv.Atoi(input.Order)
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T CONVERT STRING ORDER NUM TO INT:", err)
-		return
-	}
-	order, err := s.Service.GetOrder(ctx, models.POrder{Extnum: extnum})
+	order, err := s.Service.GetOrder(ctx, models.POrder{Extnum: input.Order})
 
 	if err != nil {
 		//w.WriteHeader(http.StatusUnprocessableEntity)
-		s.Log.Warnln("CAN'T FIND ORDER WITH NUM " + string(input.Order))
+		s.lg(r.Context()).Warnln("CAN'T FIND ORDER WITH NUM " + input.Order)
 
 		order = models.POrder{
-			Extnum: extnum,
+			Extnum: input.Order,
 		}
 		//return
 	}
 
-	_, err = s.Service.CreateWithdrawn(ctx, person, order, input.Sum)
+	_, err = s.Service.CreateWithdrawn(ctx, person, order, input.Sum, r.Header.Get("Idempotency-Key"))
 
 	if err != nil {
+		writeError(w, r, err)
+
 		if errors.Is(err, service.ErrRedSaldo) {
-			w.WriteHeader(http.StatusPaymentRequired)
-			s.Log.Warnln("RED SALDO:", err)
+			s.Metrics.Withdrawal(metrics.WithdrawalRejected)
+			s.lg(r.Context()).Warnln("RED SALDO:", err)
+			return
+		} else if errors.Is(err, service.ErrIdempotencyKeyReuse) {
+			s.Metrics.Withdrawal(metrics.WithdrawalFailed)
+			s.lg(r.Context()).Warnln("IDEMPOTENCY KEY REUSED:", err)
 			return
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			s.Log.Warnln("CAN'T CREATE PAYMENT:", err)
+			s.Metrics.Withdrawal(metrics.WithdrawalFailed)
+			s.lg(r.Context()).Warnln("CAN'T CREATE PAYMENT:", err)
 			return
 		}
 
 	}
 
+	s.Metrics.Withdrawal(metrics.WithdrawalAccepted)
 	w.WriteHeader(http.StatusOK)
 
 }
@@ -474,34 +464,35 @@ func (s *Srv) actAcctStatement(w http.ResponseWriter, r *http.Request) {
 	currPersonId, ok := ctx.Value(contextParam("CurrPersonID")).(int)
 
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		s.Log.Warnln("INVALID PERSON ID")
+		writeError(w, r, ErrUnauthorized)
+		s.lg(r.Context()).Warnln("INVALID PERSON ID")
 		return
 	}
 
 	person, err := s.Service.GetPersonByID(ctx, currPersonId)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		s.Log.Warnln("INVALID PERSON ID")
+		writeError(w, r, ErrPersonNotFound)
+		s.lg(r.Context()).Warnln("CAN'T FIND PERSON WITH ID=", currPersonId, err)
+		return
 	}
 
 	rows, err := s.Service.GetWithdrawals(ctx, person)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T GET STATMENT: [%v]", err)
+		writeError(w, r, err)
+		s.lg(r.Context()).Warnln("CAN'T GET STATMENT:", err)
 		return
 
 	}
 
 	if len(rows) == 0 {
 		w.WriteHeader(http.StatusNoContent)
-		s.Log.Warnln("STATMENT IS EMPTY")
+		s.lg(r.Context()).Warnln("STATMENT IS EMPTY")
 		return
 	}
 
-	res := make([]WithdrawalsResponce, 10)
+	res := make([]WithdrawalsResponce, 0, len(rows))
 
 	for _, opentry := range rows {
 		wr := WithdrawalsResponce{
@@ -515,20 +506,221 @@ func (s *Srv) actAcctStatement(w http.ResponseWriter, r *http.Request) {
 	output, err := json.Marshal(res)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Warnln("CAN'T MARSHAL RESPONCE: [%v]", err)
+		writeError(w, r, err)
+		s.lg(r.Context()).Warnln("CAN'T MARSHAL RESPONCE:", err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	_, err = w.Write(output)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.Log.Errorln("CAN'T WRITE DATA TO BODY:[%v]", err)
+		s.lg(r.Context()).Errorln("CAN'T WRITE DATA TO BODY:", err)
+	}
+}
+
+// startSession выдаёт access-токен и открывает новое семейство refresh-токенов.
+// При успехе ответ уже записан.
+func (s *Srv) startSession(ctx context.Context, w http.ResponseWriter, personID uint) error {
+	refresh, hash, err := s.JwtService.NewRefreshToken()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Service.SaveRefreshToken(ctx, models.RefreshToken{
+		Person:  personID,
+		Family:  hash,
+		Hash:    hash,
+		Expires: time.Now().Add(s.JwtService.RefreshExpired()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.writeTokens(w, personID, refresh)
+}
+
+// writeTokens отдаёт токены и в cookie, и в теле ответа: браузеру хватает cookie,
+// мобильному приложению и сервисам удобнее заголовок Authorization: Bearer.
+func (s *Srv) writeTokens(w http.ResponseWriter, personID uint, refresh string) error {
+	jwtToken, err := s.JwtService.GetJwtStr(int(personID))
+
+	if err != nil {
+		return fmt.Errorf("CAN'T CREATE JWT FOR USER [%w]", err)
+	}
+
+	output, err := json.Marshal(TokenResponce{
+		AccessToken:  jwtToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.JwtService.TokenExpired().Seconds()),
+		RefreshToken: refresh,
+	})
+
+	if err != nil {
+		return fmt.Errorf("CAN'T MARSHAL TOKENS [%w]", err)
+	}
+
+	http.SetCookie(w, s.Cookie.New(tokenCookie, jwtToken, s.JwtService.TokenExpired()))
+
+	refreshC := s.Cookie.New(refreshCookie, refresh, s.JwtService.RefreshExpired())
+	refreshC.Path = refreshCookiePath
+	http.SetCookie(w, refreshC)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(output); err != nil {
+		s.Log.Warnln("CAN'T WRITE TOKENS:", err)
+	}
+
+	return nil
+}
+
+func (s *Srv) readRefreshToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(refreshCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	// Без тела и без cookie токена нет — это 401, а не ошибка формата запроса.
+	if r.ContentLength == 0 {
+		return "", nil
+	}
+
+	request := TokenRefreshRequest{}
+
+	if err := s.decodeJSON(w, r, &request); err != nil {
+		if errors.Is(err, ErrEmptyBody) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return request.RefreshToken, nil
+}
+
+func (s *Srv) actTokenRefresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	refresh, err := s.readRefreshToken(w, r)
+
+	if err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Debugln("CAN'T READ REFRESH REQUEST:", err)
+		return
+	}
+
+	if refresh == "" {
+		writeError(w, r, service.ErrRefreshTokenInvalid)
+		s.lg(r.Context()).Debugln("NO REFRESH TOKEN")
+		return
+	}
+
+	next, hash, err := s.JwtService.NewRefreshToken()
+
+	if err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T CREATE REFRESH TOKEN:", err)
 		return
 	}
 
+	stored, err := s.Service.RotateRefreshToken(ctx, s.JwtService.HashRefreshToken(refresh), models.RefreshToken{
+		Hash:    hash,
+		Expires: time.Now().Add(s.JwtService.RefreshExpired()),
+	})
+
+	if err != nil {
+		writeError(w, r, err)
+
+		if errors.Is(err, service.ErrRefreshTokenReused) {
+			s.lg(r.Context()).Warnln("REFRESH TOKEN REUSE DETECTED. SESSION REVOKED")
+			return
+		}
+
+		if errors.Is(err, service.ErrRefreshTokenInvalid) {
+			s.lg(r.Context()).Debugln("INVALID REFRESH TOKEN")
+			return
+		}
+
+		s.lg(r.Context()).Errorln("CAN'T ROTATE REFRESH TOKEN:", err)
+		return
+	}
+
+	if err := s.writeTokens(w, stored.Person, next); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T WRITE TOKENS:", err)
+	}
+}
+
+func (s *Srv) actUserLogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	claims, ok := ctx.Value(contextParam("CurrTokenClaims")).(sec.Claims)
+
+	if !ok {
+		writeError(w, r, ErrUnauthorized)
+		s.lg(r.Context()).Warnln("NO TOKEN CLAIMS")
+		return
+	}
+
+	// Bearer-клиенты передают refresh-токен в теле, как и в /token/refresh
+	refresh, err := s.readRefreshToken(w, r)
+
+	if err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Debugln("CAN'T READ LOGOUT REQUEST:", err)
+		return
+	}
+
+	expires := time.Now().Add(s.JwtService.TokenExpired())
+
+	if claims.ExpiresAt != nil {
+		expires = claims.ExpiresAt.Time
+	}
+
+	if err := s.Service.RevokeToken(ctx, claims.ID, expires); err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T REVOKE TOKEN:", err)
+		return
+	}
+
+	if refresh != "" {
+		if err := s.Service.RevokeRefreshToken(ctx, s.JwtService.HashRefreshToken(refresh)); err != nil {
+			writeError(w, r, err)
+			s.lg(r.Context()).Errorln("CAN'T REVOKE REFRESH TOKEN:", err)
+			return
+		}
+	}
+
+	http.SetCookie(w, s.Cookie.New(tokenCookie, "", -1))
+
+	refreshC := s.Cookie.New(refreshCookie, "", -1)
+	refreshC.Path = refreshCookiePath
+	http.SetCookie(w, refreshC)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Srv) actJWKS(w http.ResponseWriter, r *http.Request) {
+	output, err := json.Marshal(s.JwtService.JWKS())
+
+	if err != nil {
+		writeError(w, r, err)
+		s.lg(r.Context()).Errorln("CAN'T MARSHAL JWKS:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
 	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(output); err != nil {
+		s.lg(r.Context()).Warnln("CAN'T WRITE JWKS:", err)
+	}
 }
 
 func NewServer(log logger.Lg,
@@ -536,13 +728,20 @@ func NewServer(log logger.Lg,
 	jwt IJwtService) (*Srv, error) {
 
 	var NoAuthActions = map[string]string{
-		"/api/user/register": "/api/user/register",
-		"/api/user/login":    "/api/user/login",
+		"/api/user/register":      "/api/user/register",
+		"/api/user/login":         "/api/user/login",
+		"/api/user/token/refresh": "/api/user/token/refresh",
+		"/.well-known/jwks.json":  "/.well-known/jwks.json",
+		"/healthz":                "/healthz",
+		"/readyz":                 "/readyz",
 	}
 	return &Srv{
 		Log:           log,
 		Service:       serv,
 		JwtService:    jwt,
 		NoAuthActions: NoAuthActions,
+		Cookie:        NewCookieConf(false, "lax", "/", ""),
+		Metrics:       metrics.Nop{},
+		MaxBody:       DefMaxBody,
 	}, nil
 }
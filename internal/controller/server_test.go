@@ -1,22 +1,39 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DmitryM7/yapr56.git/internal/conf"
 	"github.com/DmitryM7/yapr56.git/internal/controller/mocks"
 	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
 	"github.com/DmitryM7/yapr56.git/internal/sec"
+	"github.com/DmitryM7/yapr56.git/internal/service"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
 
+func testKeyRing(t *testing.T) *sec.KeyRing {
+	t.Helper()
+
+	ring, err := sec.BuildKeyRing(strings.Repeat("s", sec.MinSecretLen), nil, "")
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T BUILD KEY RING: [%v]", err)
+	}
+
+	return ring
+}
+
 func TestSrv_actUserRegister(t *testing.T) {
-	conf := conf.NewConf()
+	config := conf.Default()
 	logger := logger.NewLg()
 
 	ctrl := gomock.NewController(t)
@@ -24,7 +41,13 @@ func TestSrv_actUserRegister(t *testing.T) {
 
 	storageservice := mocks.NewMockIStorage(ctrl)
 
-	jwt := sec.NewJwtProvider(conf.SecretKeyTime, conf.SecretKey)
+	gomock.InOrder(
+		storageservice.EXPECT().CreatePeson(gomock.Any(), gomock.Any()).Return(models.Person{ID: 1, Login: "dmaslov"}, nil),
+		storageservice.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Return(models.RefreshToken{ID: 1}, nil),
+		storageservice.EXPECT().CreatePeson(gomock.Any(), gomock.Any()).Return(models.Person{}, service.ErrUserExists),
+	)
+
+	jwt := sec.NewJwtProvider(config.JWT.TokenTTL, config.JWT.RefreshTTL, testKeyRing(t))
 	serv, err := NewServer(logger, storageservice, jwt)
 	if err != nil {
 		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
@@ -85,13 +108,383 @@ func TestSrv_actUserRegister(t *testing.T) {
 				return
 			}
 			tt.args.r = httptest.NewRequest(tt.args.method, "/api/user/register", strings.NewReader(string(body)))
+			tt.args.r.Header.Set("Content-Type", "application/json")
 			tt.args.w = httptest.NewRecorder()
 			tt.s.actUserRegister(tt.args.w, tt.args.r)
 
 			res := tt.args.w.Result()
+			defer res.Body.Close()
 
 			assert.Equal(t, tt.want.StatusCode, res.StatusCode)
 
+			if res.StatusCode == http.StatusOK {
+				tokens := TokenResponce{}
+				assert.NoError(t, json.NewDecoder(res.Body).Decode(&tokens))
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.Equal(t, "Bearer", tokens.TokenType)
+
+				for _, c := range res.Cookies() {
+					assert.True(t, c.HttpOnly)
+					assert.Equal(t, http.SameSiteLaxMode, c.SameSite)
+				}
+			}
+
+			if res.StatusCode == http.StatusConflict {
+				p := Problem{}
+				assert.Equal(t, problemContentType, res.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+				assert.Equal(t, "LOGIN_TAKEN", p.Code)
+			}
+
+		})
+	}
+}
+
+func TestSrv_actWithdraw(t *testing.T) {
+	logger := logger.NewLg()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+
+	serv, err := NewServer(logger, storageservice, sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t)))
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	tests := []struct {
+		name       string
+		idemKey    string
+		storageErr error
+		want       int
+	}{
+		{name: "Withdrawn accepted", idemKey: "k1", want: http.StatusOK},
+		{name: "Not enough points", idemKey: "k2", storageErr: service.ErrRedSaldo, want: http.StatusPaymentRequired},
+		{name: "Idempotency key reused", idemKey: "k1", storageErr: service.ErrIdempotencyKeyReuse, want: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storageservice.EXPECT().GetPersonByID(gomock.Any(), 1).Return(models.Person{ID: 1}, nil)
+			storageservice.EXPECT().GetOrder(gomock.Any(), gomock.Any()).Return(models.POrder{}, errors.New("NOT FOUND"))
+			storageservice.EXPECT().
+				CreateWithdrawn(gomock.Any(), gomock.Any(), gomock.Any(), money.FromPoints(751), tt.idemKey).
+				Return(models.Opentry{}, tt.storageErr)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(`{"order":"2377225624","sum":751}`))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Idempotency-Key", tt.idemKey)
+			r = r.WithContext(context.WithValue(r.Context(), contextParam("CurrPersonID"), 1))
+			w := httptest.NewRecorder()
+
+			serv.actWithdraw(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want, res.StatusCode)
+		})
+	}
+}
+
+func TestSrv_actTokenRefresh(t *testing.T) {
+	logger := logger.NewLg()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	jwt := sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t))
+
+	serv, err := NewServer(logger, storageservice, jwt)
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	tests := []struct {
+		name        string
+		cookie      string
+		contentType string
+		body        string
+		storageErr  error
+		want        int
+		wantCookies bool
+	}{
+		{name: "Token rotated", cookie: "old", want: http.StatusOK, wantCookies: true},
+		{name: "Token reused", cookie: "old", storageErr: service.ErrRefreshTokenReused, want: http.StatusUnauthorized},
+		{name: "Unknown token", cookie: "unknown", storageErr: service.ErrRefreshTokenInvalid, want: http.StatusUnauthorized},
+		{name: "No token", want: http.StatusUnauthorized},
+		{name: "Token in body", contentType: jsonContentType, body: `{"refresh_token":"old"}`, want: http.StatusOK, wantCookies: true},
+		{name: "Empty token in body", contentType: jsonContentType, body: `{}`, want: http.StatusUnauthorized},
+		{name: "Body is not JSON", contentType: textContentType, body: `{"refresh_token":"old"}`, want: http.StatusUnsupportedMediaType},
+		{name: "Body too large", contentType: jsonContentType, body: `{"refresh_token":"` + strings.Repeat("x", DefMaxBody) + `"}`, want: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantCookies || tt.storageErr != nil {
+				token := tt.cookie
+				if token == "" {
+					token = "old"
+				}
+
+				storageservice.EXPECT().
+					RotateRefreshToken(gomock.Any(), jwt.HashRefreshToken(token), gomock.Any()).
+					Return(models.RefreshToken{Person: 1}, tt.storageErr)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: refreshCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			serv.actTokenRefresh(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want, res.StatusCode)
+			assert.Equal(t, tt.wantCookies, len(res.Cookies()) == 2)
+		})
+	}
+}
+
+func TestSrv_actUserLogout(t *testing.T) {
+	logger := logger.NewLg()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	jwt := sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t))
+
+	serv, err := NewServer(logger, storageservice, jwt)
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	tests := []struct {
+		name        string
+		cookie      string
+		contentType string
+		body        string
+		wantRevoked string
+		want        int
+	}{
+		{name: "Bearer client", contentType: jsonContentType, body: `{"refresh_token":"mobile"}`, wantRevoked: "mobile", want: http.StatusOK},
+		{name: "Browser client", cookie: "browser", wantRevoked: "browser", want: http.StatusOK},
+		{name: "No refresh token", want: http.StatusOK},
+		{name: "Body is not JSON", contentType: textContentType, body: `{"refresh_token":"mobile"}`, want: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want == http.StatusOK {
+				storageservice.EXPECT().RevokeToken(gomock.Any(), "jti", gomock.Any()).Return(nil)
+			}
+
+			if tt.wantRevoked != "" {
+				storageservice.EXPECT().RevokeRefreshToken(gomock.Any(), jwt.HashRefreshToken(tt.wantRevoked)).Return(nil)
+			}
+
+			claims := sec.Claims{}
+			claims.ID = "jti"
+
+			r := httptest.NewRequest(http.MethodPost, "/api/user/logout", strings.NewReader(tt.body))
+			r = r.WithContext(context.WithValue(r.Context(), contextParam("CurrTokenClaims"), claims))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: refreshCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			serv.actUserLogout(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want, res.StatusCode)
 		})
 	}
 }
+
+func TestSrv_actMiddleWare(t *testing.T) {
+	logger := logger.NewLg()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	storageservice.EXPECT().IsTokenRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	storageservice.EXPECT().GetPersonByID(gomock.Any(), 5).Return(models.Person{ID: 5}, nil).AnyTimes()
+	storageservice.EXPECT().GetPersonByID(gomock.Any(), 6).Return(models.Person{ID: 6, Status: service.PersonBlocked}, service.ErrUserBlocked).AnyTimes()
+
+	jwt := sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t))
+
+	serv, err := NewServer(logger, storageservice, jwt)
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	token, err := jwt.GetJwtStr(5)
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE JWT: [%v]", err)
+	}
+
+	blocked, err := jwt.GetJwtStr(6)
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE JWT: [%v]", err)
+	}
+
+	handler := serv.actMiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 5, r.Context().Value(contextParam("CurrPersonID")))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   int
+	}{
+		{name: "Bearer token", header: "Bearer " + token, want: http.StatusOK},
+		{name: "Lowercase scheme", header: "bearer " + token, want: http.StatusOK},
+		{name: "Cookie token", cookie: token, want: http.StatusOK},
+		{name: "Other auth scheme", header: "Basic " + token, cookie: token, want: http.StatusUnauthorized},
+		{name: "Broken token", header: "Bearer broken", want: http.StatusUnauthorized},
+		{name: "No token", want: http.StatusUnauthorized},
+		{name: "Blocked person", header: "Bearer " + blocked, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: tokenCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want, res.StatusCode)
+		})
+	}
+}
+
+func TestSrv_actAcctBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	storageservice.EXPECT().GetPersonByID(gomock.Any(), 1).Return(models.Person{ID: 1}, nil)
+	storageservice.EXPECT().GetBalance(gomock.Any(), gomock.Any()).Return(money.Money(50050), nil)
+	storageservice.EXPECT().Getwithdrawn(gomock.Any(), gomock.Any()).Return(money.Money(72998), nil)
+
+	serv, err := NewServer(logger.NewLg(), storageservice, sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t)))
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
+	r = r.WithContext(context.WithValue(r.Context(), contextParam("CurrPersonID"), 1))
+	w := httptest.NewRecorder()
+
+	serv.actAcctBalance(w, r)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"current":500.5,"withdrawn":729.98}`, w.Body.String())
+}
+
+func TestSrv_actOrdersUpload(t *testing.T) {
+	const longNumber = "1234567890123456789012340"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	storageservice.EXPECT().GetPersonByID(gomock.Any(), 1).Return(models.Person{ID: 1}, nil).AnyTimes()
+	storageservice.EXPECT().
+		CreateOrder(gomock.Any(), gomock.Any(), models.POrder{Extnum: longNumber}).
+		Return(models.POrder{Extnum: longNumber}, nil)
+
+	// ведущие нули не меняют контрольную сумму Луна
+	veryLongNumber := strings.Repeat("0", 300) + longNumber
+	storageservice.EXPECT().
+		CreateOrder(gomock.Any(), gomock.Any(), models.POrder{Extnum: veryLongNumber}).
+		Return(models.POrder{Extnum: veryLongNumber}, nil)
+
+	serv, err := NewServer(logger.NewLg(), storageservice, sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t)))
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "Long order number accepted", body: longNumber + "\n", want: http.StatusAccepted},
+		{name: "Number longer than 255 digits accepted", body: veryLongNumber, want: http.StatusAccepted},
+		{name: "Not a digit string", body: "12a45", want: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "text/plain")
+			r = r.WithContext(context.WithValue(r.Context(), contextParam("CurrPersonID"), 1))
+			w := httptest.NewRecorder()
+
+			serv.actOrdersUpload(w, r)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestSrv_actOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	storageservice.EXPECT().GetOrders(gomock.Any(), gomock.Any()).Return([]models.POrder{
+		{Extnum: "1234567890123456789012340", Status: service.Processed, Accrual: 72998},
+		{Extnum: "9278923470", Status: service.StatusNew},
+	}, nil)
+
+	serv, err := NewServer(logger.NewLg(), storageservice, sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t)))
+	if err != nil {
+		t.Fatalf("TEST ERROR. CAN'T CREATE SERVER: [%v]", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
+	r = r.WithContext(context.WithValue(r.Context(), contextParam("CurrPersonID"), 1))
+	w := httptest.NewRecorder()
+
+	serv.actOrders(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var got []map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Len(t, got, 2)
+	assert.Equal(t, "1234567890123456789012340", got[0]["number"])
+	assert.Equal(t, 729.98, got[0]["accrual"])
+	assert.NotContains(t, got[1], "accrual")
+}
@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/DmitryM7/yapr56.git/internal/service"
+)
+
+const (
+	DefMaxBody = 64 << 10
+
+	jsonContentType = "application/json"
+	textContentType = "text/plain"
+
+	minLoginLen    = 3
+	maxLoginLen    = 64
+	minPasswordLen = 8
+	maxPasswordLen = 128
+
+	errLoginCharset       = "must contain only latin letters, digits, '.', '_' or '-'"
+	errPasswordComplexity = "must contain at least one letter and one digit"
+)
+
+var (
+	ErrValidation       = errors.New("VALIDATION FAILED")
+	ErrUnsupportedMedia = errors.New("UNSUPPORTED CONTENT TYPE")
+	ErrBodyTooLarge     = errors.New("BODY TOO LARGE")
+)
+
+type (
+	// IValidator реализуют тела запросов, которые проверяются после разбора JSON.
+	IValidator interface {
+		Validate() error
+	}
+
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// ValidationError перечисляет все нарушения сразу, а не только первое.
+	ValidationError []FieldError
+
+	// rule — одна декларативная проверка поля: если ok ложно, в ответ попадает msg.
+	rule struct {
+		field string
+		ok    bool
+		msg   string
+	}
+)
+
+func (e ValidationError) Error() string {
+	parts := make([]string, 0, len(e))
+
+	for _, f := range e {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+
+	return fmt.Sprintf("%s [%s]", ErrValidation, strings.Join(parts, "; "))
+}
+
+func (e ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// check собирает сообщения по нарушенным правилам. Для одного поля выводится только
+// первое нарушение.
+func check(rules ...rule) error {
+	var result ValidationError
+
+	failed := map[string]bool{}
+
+	for _, r := range rules {
+		if r.ok || failed[r.field] {
+			continue
+		}
+
+		failed[r.field] = true
+		result = append(result, FieldError{Field: r.field, Message: r.msg})
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+func (u UserRegisterRequest) Validate() error {
+	return check(
+		rule{"login", u.Login != "", "is required"},
+		rule{"login", len(u.Login) >= minLoginLen && len(u.Login) <= maxLoginLen,
+			fmt.Sprintf("length must be between %d and %d", minLoginLen, maxLoginLen)},
+		rule{"login", validLogin(u.Login), errLoginCharset},
+		rule{"password", u.Password != "", "is required"},
+		rule{"password", len(u.Password) >= minPasswordLen && len(u.Password) <= maxPasswordLen,
+			fmt.Sprintf("length must be between %d and %d", minPasswordLen, maxPasswordLen)},
+		rule{"password", strongPassword(u.Password), errPasswordComplexity},
+	)
+}
+
+// Validate для входа проверяет только наличие полей: политика паролей могла измениться
+// после регистрации, и старые пароли должны продолжать работать.
+func (u UserAuthRequest) Validate() error {
+	return check(
+		rule{"login", u.Login != "", "is required"},
+		rule{"password", u.Password != "", "is required"},
+	)
+}
+
+// Validate не требует refresh_token: пустой токен отклоняется как недействительный (401).
+func (tr TokenRefreshRequest) Validate() error {
+	return nil
+}
+
+func (wr WithdrawRequest) Validate() error {
+	return check(
+		rule{"order", wr.Order != "", "is required"},
+		rule{"order", validOrderNumber(wr.Order), "must contain digits only"},
+		rule{"order", service.CheckLuhn(wr.Order) == nil, "fails the Luhn check"},
+		rule{"sum", wr.Sum > 0, "must be positive"},
+	)
+}
+
+func validLogin(login string) bool {
+	for _, c := range login {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func strongPassword(pass string) bool {
+	letter, digit := false, false
+
+	for _, c := range pass {
+		switch {
+		case unicode.IsLetter(c):
+			letter = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+
+	return letter && digit
+}
+
+func validOrderNumber(num string) bool {
+	// длина номера ограничена только размером тела запроса (MaxBody)
+	if num == "" {
+		return false
+	}
+
+	for _, c := range num {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readBody проверяет Content-Type и читает тело не длиннее MaxBody.
+func (s *Srv) readBody(w http.ResponseWriter, r *http.Request, contentType string) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != contentType {
+		return nil, fmt.Errorf("CAN'T ACCEPT %q: [%w]", r.Header.Get("Content-Type"), ErrUnsupportedMedia)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.MaxBody))
+
+	if err != nil {
+		if errors.As(err, new(*http.MaxBytesError)) {
+			return nil, ErrBodyTooLarge
+		}
+
+		return nil, fmt.Errorf("%w: [%v]", ErrBadBody, err)
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, ErrEmptyBody
+	}
+
+	return body, nil
+}
+
+// decodeJSON читает тело application/json в v и проверяет его.
+func (s *Srv) decodeJSON(w http.ResponseWriter, r *http.Request, v IValidator) error {
+	body, err := s.readBody(w, r, jsonContentType)
+
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: [%v]", ErrBadJSON, err)
+	}
+
+	return v.Validate()
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/controller/mocks"
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/sec"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRegisterRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    UserRegisterRequest
+		fields []string
+	}{
+		{"valid", UserRegisterRequest{Login: "d.maslov", Password: "!QAZ2wsx"}, nil},
+		{"empty", UserRegisterRequest{}, []string{"login", "password"}},
+		{"short login", UserRegisterRequest{Login: "dm", Password: "!QAZ2wsx"}, []string{"login"}},
+		{"login charset", UserRegisterRequest{Login: "dm aslov", Password: "!QAZ2wsx"}, []string{"login"}},
+		{"weak password", UserRegisterRequest{Login: "dmaslov", Password: "password"}, []string{"password"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+
+			if tt.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fields ValidationError
+			require.ErrorAs(t, err, &fields)
+			assert.ErrorIs(t, err, ErrValidation)
+
+			got := make([]string, 0, len(fields))
+			for _, f := range fields {
+				got = append(got, f.Field)
+			}
+			assert.Equal(t, tt.fields, got)
+		})
+	}
+}
+
+func TestSrv_actWithdraw_validation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storageservice := mocks.NewMockIStorage(ctrl)
+	storageservice.EXPECT().GetPersonByID(gomock.Any(), 1).Return(models.Person{ID: 1}, nil).AnyTimes()
+
+	serv, err := NewServer(logger.NewLg(), storageservice, sec.NewJwtProvider(time.Minute, time.Hour, testKeyRing(t)))
+	require.NoError(t, err)
+
+	serv.MaxBody = 64
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"wrong content type", "text/plain", `{"order":"2377225624","sum":751}`, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE"},
+		{"bad json", "application/json", `{"order":`, http.StatusBadRequest, "BAD_JSON"},
+		{"empty body", "application/json; charset=utf-8", ``, http.StatusBadRequest, "EMPTY_BODY"},
+		{"too large", "application/json", `{"order":"` + strings.Repeat("1", 100) + `"}`, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE"},
+		{"non numeric order", "application/json", `{"order":"abc","sum":751}`, http.StatusUnprocessableEntity, "VALIDATION_FAILED"},
+		{"luhn", "application/json", `{"order":"2377225625","sum":751}`, http.StatusUnprocessableEntity, "VALIDATION_FAILED"},
+		{"negative sum", "application/json", `{"order":"2377225624","sum":-1}`, http.StatusUnprocessableEntity, "VALIDATION_FAILED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			r = r.WithContext(context.WithValue(r.Context(), contextParam("CurrPersonID"), 1))
+			w := httptest.NewRecorder()
+
+			serv.actWithdraw(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.status, res.StatusCode)
+
+			p := Problem{}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+			assert.Equal(t, tt.code, p.Code)
+
+			if tt.code == "VALIDATION_FAILED" {
+				assert.NotEmpty(t, p.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+)
+
+const DefStopTimeout = 10 * time.Second
+
+type (
+	// Hook — подсистема приложения. Start должен быстро вернуть управление: долгую
+	// работу запускают в горутине, а о её аварийном завершении сообщают через Fail.
+	// StopTimeout ограничивает Stop этого хука; ноль означает StopTimeout менеджера.
+	Hook struct {
+		Name        string
+		Start       func(ctx context.Context) error
+		Stop        func(ctx context.Context) error
+		StopTimeout time.Duration
+	}
+
+	// Manager запускает хуки в порядке регистрации и останавливает в обратном
+	// по SIGINT/SIGTERM, отмене контекста или ошибке подсистемы.
+	Manager struct {
+		Log         logger.Lg
+		StopTimeout time.Duration
+		hooks       []Hook
+		started     int
+		errs        chan error
+		once        sync.Once
+	}
+)
+
+func NewManager(log logger.Lg, stopTimeout time.Duration) *Manager {
+	if stopTimeout <= 0 {
+		stopTimeout = DefStopTimeout
+	}
+
+	return &Manager{
+		Log:         log,
+		StopTimeout: stopTimeout,
+		errs:        make(chan error, 1),
+	}
+}
+
+func (m *Manager) Append(h Hook) {
+	m.hooks = append(m.hooks, h)
+}
+
+// Fail сообщает о падении подсистемы и запускает остановку приложения.
+func (m *Manager) Fail(err error) {
+	select {
+	case m.errs <- err:
+	default:
+	}
+}
+
+// Run запускает хуки и блокируется до сигнала остановки. Возвращает первую ошибку
+// запуска или работы подсистемы, а также ошибки остановки.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var errRun error
+
+	for _, h := range m.hooks {
+		if h.Start != nil {
+			if err := h.Start(ctx); err != nil {
+				errRun = fmt.Errorf("CAN'T START %s [%w]", h.Name, err)
+				break
+			}
+		}
+
+		m.started++
+		m.Log.Debugln("STARTED:", h.Name)
+	}
+
+	if errRun == nil {
+		select {
+		case <-ctx.Done():
+			m.Log.Infoln("STOP SIGNAL RECEIVED")
+		case err := <-m.errs:
+			errRun = err
+		}
+	}
+
+	stop()
+
+	return errors.Join(errRun, m.Shutdown())
+}
+
+// Shutdown останавливает запущенные хуки в обратном порядке. Каждому хуку отводится
+// свой таймаут: HTTP-сервер, долго ждущий медленные запросы, не отнимает время у
+// воркеров, которым надо доделать текущий заказ.
+func (m *Manager) Shutdown() error {
+	var errStop error
+
+	m.once.Do(func() {
+		errs := make([]error, 0, m.started)
+
+		for i := m.started - 1; i >= 0; i-- {
+			h := m.hooks[i]
+
+			if h.Stop == nil {
+				continue
+			}
+
+			if err := m.stop(h); err != nil {
+				m.Log.Warnln("CAN'T STOP", h.Name, err)
+				errs = append(errs, fmt.Errorf("CAN'T STOP %s [%w]", h.Name, err))
+				continue
+			}
+
+			m.Log.Debugln("STOPPED:", h.Name)
+		}
+
+		errStop = errors.Join(errs...)
+	})
+
+	return errStop
+}
+
+func (m *Manager) stop(h Hook) error {
+	timeout := h.StopTimeout
+
+	if timeout <= 0 {
+		timeout = m.StopTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return h.Stop(ctx)
+}
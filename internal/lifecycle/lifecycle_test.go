@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Run(t *testing.T) {
+	errBoom := errors.New("BOOM")
+
+	tests := []struct {
+		name      string
+		failStart string
+		failRun   bool
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name:      "stop in reverse order",
+			wantCalls: []string{"start db", "start http", "stop http", "stop db"},
+		},
+		{
+			name:      "subsystem failure stops application",
+			failRun:   true,
+			wantCalls: []string{"start db", "start http", "stop http", "stop db"},
+			wantErr:   errBoom,
+		},
+		{
+			name:      "start failure stops only started hooks",
+			failStart: "http",
+			wantCalls: []string{"start db", "start http", "stop db"},
+			wantErr:   errBoom,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(logger.NewLg(), time.Second)
+			calls := []string{}
+
+			for _, name := range []string{"db", "http"} {
+				m.Append(Hook{
+					Name: name,
+					Start: func(context.Context) error {
+						calls = append(calls, "start "+name)
+						if name == tt.failStart {
+							return errBoom
+						}
+						return nil
+					},
+					Stop: func(context.Context) error {
+						calls = append(calls, "stop "+name)
+						return nil
+					},
+				})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tt.failRun {
+				m.Fail(errBoom)
+			} else {
+				cancel()
+			}
+
+			err := m.Run(ctx)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestManager_ShutdownBudgetPerHook(t *testing.T) {
+	m := NewManager(logger.NewLg(), 50*time.Millisecond)
+
+	var workersErr error
+
+	m.Append(Hook{
+		Name: "accrual workers",
+		Stop: func(ctx context.Context) error {
+			workersErr = ctx.Err()
+			return nil
+		},
+	})
+
+	m.Append(Hook{
+		Name: "http",
+		Stop: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := m.Run(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "http used up its deadline")
+	assert.NoError(t, workersErr, "workers get their own deadline")
+}
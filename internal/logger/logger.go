@@ -1,30 +1,174 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-type Lg struct {
-	*zap.SugaredLogger
-}
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+
+	DefSampleInitial    = 100
+	DefSampleThereafter = 100
+
+	// NameAccess и NameAudit — имена логгеров журнала доступа и аудита. Их записи
+	// не сэмплируются: у всех строк одно сообщение, и сэмплер по уровню и тексту
+	// отбросил бы большую часть запросов.
+	NameAccess = "access"
+	NameAudit  = "audit"
+)
+
+type (
+	Lg struct {
+		*zap.SugaredLogger
+		level *zap.AtomicLevel
+	}
+
+	// Conf — уровень и формат логов. Sampling пропускает в секунду первые
+	// SampleInitial одинаковых записей, затем каждую SampleThereafter-ю; 0 отключает.
+	Conf struct {
+		Level            string
+		Format           string
+		SampleInitial    int
+		SampleThereafter int
+	}
+
+	ctxKey struct{}
 
+	// sampledCore сэмплирует записи всех логгеров, кроме журналов доступа и аудита,
+	// которые пишутся в raw без сэмплера.
+	sampledCore struct {
+		zapcore.Core
+		raw zapcore.Core
+	}
+)
+
+// NewLg создаёт логгер для разработки и тестов: консольный вывод, уровень debug.
 func NewLg() Lg {
-	logger, err := zap.NewDevelopment()
+	lg, err := New(Conf{Level: "debug", Format: FormatConsole})
 
 	if err != nil {
 		panic("CAN'T INIT ZAP LOGGER")
 	}
 
-	sugar := logger.Sugar()
+	return lg
+}
+
+// New создаёт логгер по настройкам. В формате json используется продакшн-кодировщик.
+// Все записи проходят через маскирование паролей, токенов и тел запросов.
+func New(conf Conf) (Lg, error) {
+	level, err := zapcore.ParseLevel(conf.Level)
+
+	if err != nil {
+		return Lg{}, fmt.Errorf("CAN'T PARSE LOG LEVEL [%w]", err)
+	}
+
+	var zc zap.Config
+
+	switch strings.ToLower(conf.Format) {
+	case "", FormatConsole:
+		zc = zap.NewDevelopmentConfig()
+	case FormatJSON:
+		zc = zap.NewProductionConfig()
+		zc.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	default:
+		return Lg{}, fmt.Errorf("UNKNOWN LOG FORMAT [%s]", conf.Format)
+	}
+
+	atomicLevel := zap.NewAtomicLevelAt(level)
+	zc.Level = atomicLevel
+	zc.Sampling = nil
 
-	defer func() {
-		err := logger.Sync()
-		if err != nil {
-			log.Println("CAN'T SYNC LOGGER:", err)
+	logger, err := zc.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		core = newRedactCore(core)
+
+		if conf.SampleInitial > 0 && conf.SampleThereafter > 0 {
+			core = sampledCore{
+				Core: zapcore.NewSamplerWithOptions(core, time.Second, conf.SampleInitial, conf.SampleThereafter),
+				raw:  core,
+			}
 		}
-	}()
 
-	return Lg{SugaredLogger: sugar}
+		return core
+	}))
+
+	if err != nil {
+		return Lg{}, fmt.Errorf("CAN'T BUILD ZAP LOGGER [%w]", err)
+	}
+
+	return Lg{SugaredLogger: logger.Sugar(), level: &atomicLevel}, nil
+}
+
+// SetLevel меняет уровень логгера на ходу. Действует на все логгеры, полученные
+// из него через With.
+func (l Lg) SetLevel(level string) error {
+	lvl, err := zapcore.ParseLevel(level)
+
+	if err != nil {
+		return fmt.Errorf("CAN'T PARSE LOG LEVEL [%w]", err)
+	}
+
+	if l.level == nil {
+		return errors.New("LOGGER LEVEL CAN'T BE CHANGED")
+	}
+
+	l.level.SetLevel(lvl)
+
+	return nil
+}
+
+// With возвращает дочерний логгер с дополнительными полями.
+func (l Lg) With(args ...any) Lg {
+	return Lg{SugaredLogger: l.SugaredLogger.With(args...), level: l.level}
+}
+
+// Named возвращает дочерний логгер с именем name, например NameAccess.
+func (l Lg) Named(name string) Lg {
+	return Lg{SugaredLogger: l.SugaredLogger.Named(name), level: l.level}
+}
+
+func (c sampledCore) With(fields []zapcore.Field) zapcore.Core {
+	return sampledCore{Core: c.Core.With(fields), raw: c.raw.With(fields)}
+}
+
+func (c sampledCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.LoggerName == NameAccess || entry.LoggerName == NameAudit {
+		return c.raw.Check(entry, ce)
+	}
+
+	return c.Core.Check(entry, ce)
+}
+
+// Flush сбрасывает буферы логгера. Ошибки Sync для терминала и пайпа
+// (EINVAL, ENOTTY) не считаются ошибками.
+func (l Lg) Flush() error {
+	err := l.Sync()
+
+	if err == nil || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+		return nil
+	}
+
+	return err
+}
+
+// WithContext кладёт логгер запроса в контекст.
+func WithContext(ctx context.Context, l Lg) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext возвращает логгер запроса или def, если его нет.
+func FromContext(ctx context.Context, def Lg) Lg {
+	if l, ok := ctx.Value(ctxKey{}).(Lg); ok {
+		return l
+	}
+
+	return def
 }
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLg_SetLevel(t *testing.T) {
+	lg, err := New(Conf{Level: "info", Format: FormatJSON})
+	require.NoError(t, err)
+
+	child := lg.With("component", "test")
+	assert.False(t, child.Desugar().Core().Enabled(zapcore.DebugLevel), "debug is off at info level")
+
+	require.NoError(t, lg.SetLevel("debug"))
+	assert.True(t, child.Desugar().Core().Enabled(zapcore.DebugLevel), "child follows parent level")
+
+	assert.Error(t, lg.SetLevel("loud"))
+	assert.Error(t, Lg{}.SetLevel("info"))
+}
+
+func TestSampledCore_accessNotSampled(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	lg := Lg{SugaredLogger: zap.New(sampledCore{
+		Core: zapcore.NewSamplerWithOptions(core, time.Second, 1, 1000),
+		raw:  core,
+	}).Sugar()}
+
+	req := lg.With("request_id", "r1")
+
+	for range 10 {
+		req.Infoln("SAME")
+		req.Named(NameAccess).Infow("ACCESS", "status", 200)
+	}
+
+	assert.Equal(t, 1, logs.FilterMessage("SAME").Len(), "ordinary entries are sampled")
+	assert.Equal(t, 10, logs.FilterMessage("ACCESS").Len(), "every access entry is kept")
+	assert.Equal(t, "r1", logs.FilterMessage("ACCESS").All()[0].ContextMap()["request_id"])
+}
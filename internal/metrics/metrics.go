@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "gophermart"
+
+	WithdrawalAccepted = "accepted"
+	WithdrawalRejected = "rejected"
+	WithdrawalFailed   = "failed"
+
+	scrapeTimeout = 2 * time.Second
+)
+
+type (
+	// Nop — пустая реализация для тестов и запуска без метрик.
+	Nop struct{}
+
+	// Prom собирает метрики в собственный реестр Prometheus.
+	Prom struct {
+		registry     *prometheus.Registry
+		httpRequests *prometheus.CounterVec
+		httpDuration *prometheus.HistogramVec
+		accrualPolls *prometheus.CounterVec
+		withdrawals  *prometheus.CounterVec
+		points       *prometheus.CounterVec
+	}
+
+	dbStatsCollector struct {
+		stats func() sql.DBStats
+		descs map[string]*prometheus.Desc
+	}
+
+	orderStatsCollector struct {
+		count func(ctx context.Context) (map[string]int, error)
+		desc  *prometheus.Desc
+	}
+)
+
+func (Nop) ObserveHTTP(method, route string, status int, d time.Duration) {}
+func (Nop) AccrualPoll(outcome string)                                    {}
+func (Nop) Withdrawal(result string)                                      {}
+func (Nop) PointsIssued(points float64)                                   {}
+func (Nop) PointsWithdrawn(points float64)                                {}
+
+func NewProm() *Prom {
+	p := &Prom{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by chi route, method and status code.",
+		}, []string{"method", "route", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by chi route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		accrualPolls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "accrual_polls_total",
+			Help:      "Accrual system polls by outcome.",
+		}, []string{"outcome"}),
+		withdrawals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "withdrawals_total",
+			Help:      "Withdrawal requests by result.",
+		}, []string{"result"}),
+		points: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "points_total",
+			Help:      "Loyalty points posted to the ledger by operation.",
+		}, []string{"operation"}),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.httpRequests,
+		p.httpDuration,
+		p.accrualPolls,
+		p.withdrawals,
+		p.points,
+	)
+
+	return p
+}
+
+func (p *Prom) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{Registry: p.registry})
+}
+
+func (p *Prom) ObserveHTTP(method, route string, status int, d time.Duration) {
+	p.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	p.httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+func (p *Prom) AccrualPoll(outcome string) {
+	p.accrualPolls.WithLabelValues(outcome).Inc()
+}
+
+func (p *Prom) Withdrawal(result string) {
+	p.withdrawals.WithLabelValues(result).Inc()
+}
+
+func (p *Prom) PointsIssued(points float64) {
+	p.points.WithLabelValues("issued").Add(points)
+}
+
+func (p *Prom) PointsWithdrawn(points float64) {
+	p.points.WithLabelValues("withdrawn").Add(points)
+}
+
+// RegisterDBStats публикует статистику пула соединений sql.DB.
+func (p *Prom) RegisterDBStats(stats func() sql.DBStats) {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, nil)
+	}
+
+	p.registry.MustRegister(&dbStatsCollector{
+		stats: stats,
+		descs: map[string]*prometheus.Desc{
+			"max_open":      desc("max_open_connections", "Maximum number of open connections."),
+			"open":          desc("open_connections", "Established connections, in use and idle."),
+			"in_use":        desc("in_use_connections", "Connections currently in use."),
+			"idle":          desc("idle_connections", "Idle connections."),
+			"wait_count":    desc("wait_count_total", "Total number of connections waited for."),
+			"wait_duration": desc("wait_duration_seconds_total", "Total time blocked waiting for a connection."),
+		},
+	})
+}
+
+// RegisterOrderStats публикует число заказов по статусам. count вызывается при каждом сборе.
+func (p *Prom) RegisterOrderStats(count func(ctx context.Context) (map[string]int, error)) {
+	p.registry.MustRegister(&orderStatsCollector{
+		count: count,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "orders"),
+			"Orders by status.", []string{"status"}, nil),
+	})
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descs {
+		ch <- d
+	}
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+
+	ch <- prometheus.MustNewConstMetric(c.descs["max_open"], prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.descs["open"], prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.descs["in_use"], prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.descs["idle"], prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.descs["wait_count"], prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.descs["wait_duration"], prometheus.CounterValue, s.WaitDuration.Seconds())
+}
+
+func (c *orderStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *orderStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProm_Handler(t *testing.T) {
+	p := NewProm()
+	p.RegisterDBStats(func() sql.DBStats { return sql.DBStats{OpenConnections: 3, InUse: 1} })
+	p.RegisterOrderStats(func(context.Context) (map[string]int, error) {
+		return map[string]int{"NEW": 2, "PROCESSED": 5}, nil
+	})
+
+	p.ObserveHTTP(http.MethodPost, "/api/user/balance/withdraw", http.StatusPaymentRequired, 10*time.Millisecond)
+	p.Withdrawal(WithdrawalRejected)
+	p.AccrualPoll("processed")
+	p.PointsIssued(500)
+	p.PointsWithdrawn(200)
+
+	w := httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		`gophermart_http_requests_total{code="402",method="POST",route="/api/user/balance/withdraw"} 1`,
+		`gophermart_withdrawals_total{result="rejected"} 1`,
+		`gophermart_accrual_polls_total{outcome="processed"} 1`,
+		`gophermart_points_total{operation="issued"} 500`,
+		`gophermart_points_total{operation="withdrawn"} 200`,
+		`gophermart_orders{status="PROCESSED"} 5`,
+		`gophermart_db_open_connections 3`,
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
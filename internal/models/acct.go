@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/money"
+)
 
 type Acct struct {
 	ID     int
@@ -17,9 +21,9 @@ type AcctBal struct {
 	Person  int
 	Opdate  time.Time
 	Acct    string
-	Balance int
-	Db      int //nolint:stylecheck //It's debit neither DB
-	Cr      int
+	Balance money.Money
+	Db      money.Money //nolint:stylecheck //It's debit neither DB
+	Cr      money.Money
 	Crdt    time.Time
 	Updt    time.Time
 }
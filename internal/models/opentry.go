@@ -1,18 +1,22 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/money"
+)
 
 type Opentry struct {
 	ID          uint
 	Person      uint
 	Porder      uint
-	OrderExtNum int
+	OrderExtNum string
 	Status      string
 	Opdate      time.Time
 	Acctdb      string
 	Acctcr      string
-	Sum1        int
-	Sum2        int
+	Sum1        money.Money
+	Sum2        money.Money
 	Crdt        time.Time
 	Updt        time.Time
 }
@@ -1,15 +1,19 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/money"
+)
 
 type POrder struct {
-	ID      uint      `json:"-"`
-	Pid     uint      `json:"-"`
-	Extnum  int       `json:"number"`
-	Status  string    `json:"status"`
-	Accrual int       `json:"accrual"`
-	Crdt    time.Time `json:"uploaded_at"`
-	Updt    time.Time `json:"-"`
+	ID      uint        `json:"-"`
+	Pid     uint        `json:"-"`
+	Extnum  string      `json:"number"`
+	Status  string      `json:"status"`
+	Accrual money.Money `json:"accrual,omitempty"`
+	Crdt    time.Time   `json:"uploaded_at"`
+	Updt    time.Time   `json:"-"`
 }
 
 func (o *POrder) GetPID() uint {
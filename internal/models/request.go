@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Request — сохранённые для разбора обращений тела запроса (Intext) и ответа (Outtext).
+type Request struct {
+	ID        uint
+	Pid       uint
+	RequestID string
+	Method    string
+	Path      string
+	Status    int
+	Intext    string
+	Outtext   string
+	Crdt      time.Time
+	Updt      time.Time
+}
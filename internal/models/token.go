@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID      uint
+	Person  uint
+	Family  string
+	Hash    string
+	Expires time.Time
+	Used    bool
+	Revoked bool
+	Crdt    time.Time
+	Updt    time.Time
+}
+
+func (t *RefreshToken) IsActive() bool {
+	return !t.Used && !t.Revoked && time.Now().Before(t.Expires)
+}
@@ -0,0 +1,172 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale — число минорных единиц (копеек) в одном балле.
+const (
+	Scale     = 100
+	Precision = 2
+)
+
+var (
+	ErrInvalid  = errors.New("INVALID AMOUNT")
+	ErrOverflow = errors.New("AMOUNT OVERFLOW")
+)
+
+// Money — сумма в минорных единицах. Хранится в БД как BIGINT, в JSON выводится точным
+// десятичным числом без прохода через float.
+type Money int64
+
+// FromPoints возвращает сумму из целого числа баллов.
+func FromPoints(points int64) Money {
+	return Money(points * Scale)
+}
+
+// Parse разбирает десятичную запись вида "729.98", "-1", "500.5". Дробная часть — не
+// больше Precision знаков, экспонента не допускается.
+func Parse(s string) (Money, error) {
+	if s == "" {
+		return 0, fmt.Errorf("EMPTY STRING: [%w]", ErrInvalid)
+	}
+
+	neg := false
+
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+
+	if intPart == "" || (hasDot && fracPart == "") || !digits(intPart) || !digits(fracPart) {
+		return 0, fmt.Errorf("CAN'T PARSE %q: [%w]", s, ErrInvalid)
+	}
+
+	// нули в конце дробной части не меняют сумму: 1.500 — это 1.5
+	fracPart = strings.TrimRight(fracPart, "0")
+
+	if len(fracPart) > Precision {
+		return 0, fmt.Errorf("MORE THAN %d DECIMAL PLACES IN %q: [%w]", Precision, s, ErrInvalid)
+	}
+
+	whole, err := strconv.ParseInt(intPart, 10, 64)
+
+	if err != nil || whole > math.MaxInt64/Scale {
+		return 0, fmt.Errorf("CAN'T PARSE %q: [%w]", s, ErrOverflow)
+	}
+
+	frac := int64(0)
+
+	if fracPart != "" {
+		frac, _ = strconv.ParseInt(fracPart+strings.Repeat("0", Precision-len(fracPart)), 10, 64)
+	}
+
+	// при whole == MaxInt64/Scale переполнение даёт уже дробная часть
+	if whole*Scale > math.MaxInt64-frac {
+		return 0, fmt.Errorf("CAN'T PARSE %q: [%w]", s, ErrOverflow)
+	}
+
+	m := whole*Scale + frac
+
+	if neg {
+		m = -m
+	}
+
+	return Money(m), nil
+}
+
+// String выводит сумму без лишних нулей: 50050 -> "500.5", 72998 -> "729.98", 100 -> "1".
+func (m Money) String() string {
+	v := int64(m)
+	sign := ""
+
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	whole, frac := v/Scale, v%Scale
+
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+
+	f := strings.TrimRight(fmt.Sprintf("%0*d", Precision, frac), "0")
+
+	return sign + strconv.FormatInt(whole, 10) + "." + f
+}
+
+// Float64 нужен только для метрик и других приближённых значений.
+func (m Money) Float64() float64 {
+	return float64(m) / Scale
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON принимает число или строку с числом.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+
+	if s == "null" {
+		return nil
+	}
+
+	s = strings.Trim(s, `"`)
+
+	// 1e2 и подобные записи допустимы в JSON, но не нужны клиентам и усложняют разбор
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("EXPONENT IN %q: [%w]", s, ErrInvalid)
+	}
+
+	v, err := Parse(s)
+
+	if err != nil {
+		return err
+	}
+
+	*m = v
+
+	return nil
+}
+
+// Value хранит сумму в БД целым числом копеек. Без него драйвер pgx записал бы
+// строковое представление из String.
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan читает сумму в копейках; NULL означает ноль.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	default:
+		return fmt.Errorf("CAN'T SCAN %T INTO MONEY: [%w]", src, ErrInvalid)
+	}
+
+	return nil
+}
+
+func digits(s string) bool {
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
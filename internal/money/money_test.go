@@ -0,0 +1,87 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{"729.98", 72998, nil},
+		{"500.5", 50050, nil},
+		{"500.50", 50050, nil},
+		{"751", 75100, nil},
+		{"0.01", 1, nil},
+		{"-1.5", -150, nil},
+		{"1.500", 150, nil},
+		{"1.005", 0, ErrInvalid},
+		{"1.", 0, ErrInvalid},
+		{".5", 0, ErrInvalid},
+		{"abc", 0, ErrInvalid},
+		{"", 0, ErrInvalid},
+		{"99999999999999999999", 0, ErrOverflow},
+		{"92233720368547758.07", math.MaxInt64, nil},
+		{"92233720368547758.08", 0, ErrOverflow},
+		{"92233720368547758.99", 0, ErrOverflow},
+		{"-92233720368547758.07", -math.MaxInt64, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var v struct {
+		Current   Money `json:"current"`
+		Withdrawn Money `json:"withdrawn"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"current":500.5,"withdrawn":"42"}`), &v))
+
+	assert.Equal(t, Money(50050), v.Current)
+	assert.Equal(t, FromPoints(42), v.Withdrawn)
+
+	v.Current = 72998
+
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"current":729.98,"withdrawn":42}`, string(out))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"current":1e2}`), &v))
+	assert.Error(t, json.Unmarshal([]byte(`{"current":0.001}`), &v))
+}
+
+func TestMoney_SQL(t *testing.T) {
+	v, err := Money(50050).Value()
+	require.NoError(t, err)
+	assert.Equal(t, int64(50050), v, "stored as minor units, not as String()")
+
+	var m Money
+
+	require.NoError(t, m.Scan(int64(72998)))
+	assert.Equal(t, Money(72998), m)
+
+	require.NoError(t, m.Scan(nil))
+	assert.Equal(t, Money(0), m)
+
+	assert.ErrorIs(t, m.Scan("729.98"), ErrInvalid)
+}
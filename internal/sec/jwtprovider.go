@@ -1,12 +1,22 @@
 package sec
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+const (
+	jtiLen          = 16
+	refreshTokenLen = 32
+)
+
 type (
 	Claims struct {
 		jwt.RegisteredClaims
@@ -14,27 +24,99 @@ type (
 	}
 
 	JwtProvider struct {
-		TokenExpTime time.Duration
-		SecretKey    string
+		TokenExpTime   time.Duration
+		RefreshExpTime time.Duration
+		Keys           *KeyRing
 	}
 )
 
-func NewJwtProvider(tokenexp time.Duration, seckey string) JwtProvider {
+func NewJwtProvider(tokenexp, refreshexp time.Duration, keys *KeyRing) JwtProvider {
 	return JwtProvider{
-		TokenExpTime: tokenexp,
-		SecretKey:    seckey,
+		TokenExpTime:   tokenexp,
+		RefreshExpTime: refreshexp,
+		Keys:           keys,
+	}
+}
+
+// BuildKeyRing собирает связку из HMAC-секрета и PEM-файлов (kid -> путь). Подписывает
+// ключ signingKid, а если он не задан — HMAC-ключ либо первый по kid приватный PEM-ключ.
+func BuildKeyRing(secret string, pemFiles map[string]string, signingKid string) (*KeyRing, error) {
+	ring := NewKeyRing()
+
+	if secret != "" {
+		key, err := NewHMACKey(secret)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ring.Add(key); err != nil {
+			return nil, err
+		}
+
+		if signingKid == "" {
+			signingKid = key.Kid
+		}
+	}
+
+	kids := make([]string, 0, len(pemFiles))
+	for kid := range pemFiles {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key, err := LoadPEMKey(kid, pemFiles[kid])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ring.Add(key); err != nil {
+			return nil, err
+		}
+
+		if signingKid == "" && key.Sign != nil {
+			signingKid = kid
+		}
+	}
+
+	if signingKid == "" {
+		return nil, fmt.Errorf("%w: SET SECRET KEY OR PRIVATE PEM KEY", ErrNoSigningKey)
+	}
+
+	if err := ring.SetCurrent(signingKid); err != nil {
+		return nil, err
 	}
+
+	return ring, nil
 }
 
 func (j JwtProvider) GetJwtStr(uid int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	jti, err := randomString(jtiLen)
+
+	if err != nil {
+		return "", fmt.Errorf("CAN'T CREATE JTI: [%w]", err)
+	}
+
+	key, err := j.Keys.Current()
+
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.TokenExpTime)),
 		},
 		UserID: uid,
 	})
 
-	tokenString, err := token.SignedString([]byte(j.SecretKey))
+	token.Header["kid"] = key.Kid
+
+	tokenString, err := token.SignedString(key.Sign)
 
 	if err != nil {
 		return "", fmt.Errorf("CAN'T CREATE SIGNED STRING: [%w]", err)
@@ -42,20 +124,44 @@ func (j JwtProvider) GetJwtStr(uid int) (string, error) {
 	return tokenString, nil
 }
 
-func (j JwtProvider) UnloadUserIDJwt(tokenString string) (int, error) {
+// ParseJwt проверяет подпись и срок действия токена и возвращает его утверждения.
+func (j JwtProvider) ParseJwt(tokenString string) (Claims, error) {
 	// создаём экземпляр структуры с утверждениями
-	claims := &Claims{}
+	claims := Claims{}
 	// парсим из строки токена tokenString в структуру claims
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(j.SecretKey), nil
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key, err := j.Keys.Get(kid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// алгоритм задаёт ключ, а не заголовок токена
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("UNEXPECTED SIGNING METHOD [%v]", t.Header["alg"])
+		}
+
+		return key.Verify, nil
 	})
 
 	if err != nil {
-		return -1, fmt.Errorf("CAN'T ParseWithClaims: [%w]", err)
+		return claims, fmt.Errorf("CAN'T ParseWithClaims: [%w]", err)
 	}
 
 	if !token.Valid {
-		return -1, fmt.Errorf("TOKEN IS'T VALID")
+		return claims, fmt.Errorf("TOKEN IS'T VALID")
+	}
+
+	return claims, nil
+}
+
+func (j JwtProvider) UnloadUserIDJwt(tokenString string) (int, error) {
+	claims, err := j.ParseJwt(tokenString)
+
+	if err != nil {
+		return -1, err
 	}
 
 	// возвращаем ID пользователя в читаемом виде
@@ -63,6 +169,41 @@ func (j JwtProvider) UnloadUserIDJwt(tokenString string) (int, error) {
 	return claims.UserID, nil
 }
 
+func (j JwtProvider) JWKS() JWKSet {
+	return j.Keys.JWKS()
+}
+
 func (j JwtProvider) TokenExpired() time.Duration {
 	return j.TokenExpTime
 }
+
+func (j JwtProvider) RefreshExpired() time.Duration {
+	return j.RefreshExpTime
+}
+
+// NewRefreshToken создаёт непрозрачный refresh-токен. Клиенту отдаётся token,
+// в БД хранится только hash.
+func (j JwtProvider) NewRefreshToken() (token, hash string, err error) {
+	token, err = randomString(refreshTokenLen)
+
+	if err != nil {
+		return "", "", fmt.Errorf("CAN'T CREATE REFRESH TOKEN: [%w]", err)
+	}
+
+	return token, j.HashRefreshToken(token), nil
+}
+
+func (j JwtProvider) HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,268 @@
+package sec
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	MinSecretLen = 32
+	hmacKidLen   = 8
+	minRSABits   = 2048
+)
+
+var (
+	ErrEmptySecret     = errors.New("JWT SECRET KEY IS EMPTY")
+	ErrWeakSecret      = errors.New("JWT SECRET KEY IS TOO SHORT")
+	ErrNoSigningKey    = errors.New("NO JWT SIGNING KEY")
+	ErrUnknownKid      = errors.New("UNKNOWN JWT KEY ID")
+	ErrUnsupportedKey  = errors.New("UNSUPPORTED JWT KEY")
+	ErrVerifyOnlyKey   = errors.New("JWT KEY CAN'T SIGN")
+	ErrDuplicateKeyKid = errors.New("DUPLICATE JWT KEY ID")
+)
+
+type (
+	// SigningKey — ключ подписи или проверки токенов. У ключа, загруженного
+	// из публичного PEM, Sign пустой: им можно только проверять.
+	SigningKey struct {
+		Kid    string
+		Method jwt.SigningMethod
+		Sign   crypto.PrivateKey
+		Verify crypto.PublicKey
+	}
+
+	// KeyRing хранит все действующие ключи проверки по kid и один текущий ключ подписи.
+	KeyRing struct {
+		current string
+		keys    map[string]SigningKey
+	}
+
+	JWK struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n,omitempty"`
+		E   string `json:"e,omitempty"`
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+		Y   string `json:"y,omitempty"`
+	}
+
+	JWKSet struct {
+		Keys []JWK `json:"keys"`
+	}
+)
+
+// NewHMACKey создаёт HS256-ключ. kid выводится из хеша секрета, так что смена
+// секрета меняет и kid.
+func NewHMACKey(secret string) (SigningKey, error) {
+	if secret == "" {
+		return SigningKey{}, ErrEmptySecret
+	}
+
+	if len(secret) < MinSecretLen {
+		return SigningKey{}, fmt.Errorf("%w: NEED AT LEAST %d BYTES", ErrWeakSecret, MinSecretLen)
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+
+	return SigningKey{
+		Kid:    "hs-" + hex.EncodeToString(sum[:])[:hmacKidLen],
+		Method: jwt.SigningMethodHS256,
+		Sign:   []byte(secret),
+		Verify: []byte(secret),
+	}, nil
+}
+
+// LoadPEMKey читает RSA, ECDSA или Ed25519 ключ из PEM-файла. Приватный ключ
+// годится для подписи и проверки, публичный — только для проверки.
+func LoadPEMKey(kid, path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("CAN'T READ JWT KEY FILE [%w]", err)
+	}
+
+	return ParsePEMKey(kid, data)
+}
+
+func ParsePEMKey(kid string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("%w: NO PEM BLOCK FOR [%s]", ErrUnsupportedKey, kid)
+	}
+
+	var (
+		key any
+		err error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("%w: PEM TYPE [%s]", ErrUnsupportedKey, block.Type)
+	}
+
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("CAN'T PARSE JWT KEY [%s]: [%w]", kid, err)
+	}
+
+	return newAsymmetricKey(kid, key)
+}
+
+func newAsymmetricKey(kid string, key any) (SigningKey, error) {
+	sk := SigningKey{Kid: kid}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sk.Sign, sk.Verify = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		sk.Sign, sk.Verify = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		sk.Sign, sk.Verify = k, k.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		sk.Verify = k
+	default:
+		return SigningKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+
+	switch k := sk.Verify.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return SigningKey{}, fmt.Errorf("%w: RSA KEY [%s] SHORTER THAN %d BITS", ErrUnsupportedKey, kid, minRSABits)
+		}
+		sk.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			sk.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			sk.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			sk.Method = jwt.SigningMethodES512
+		default:
+			return SigningKey{}, fmt.Errorf("%w: EC CURVE FOR [%s]", ErrUnsupportedKey, kid)
+		}
+	case ed25519.PublicKey:
+		sk.Method = jwt.SigningMethodEdDSA
+	}
+
+	return sk, nil
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: map[string]SigningKey{}}
+}
+
+func (k *KeyRing) Add(key SigningKey) error {
+	if _, ok := k.keys[key.Kid]; ok {
+		return fmt.Errorf("%w [%s]", ErrDuplicateKeyKid, key.Kid)
+	}
+
+	k.keys[key.Kid] = key
+
+	return nil
+}
+
+func (k *KeyRing) SetCurrent(kid string) error {
+	key, ok := k.keys[kid]
+
+	if !ok {
+		return fmt.Errorf("%w [%s]", ErrUnknownKid, kid)
+	}
+
+	if key.Sign == nil {
+		return fmt.Errorf("%w [%s]", ErrVerifyOnlyKey, kid)
+	}
+
+	k.current = kid
+
+	return nil
+}
+
+func (k *KeyRing) Current() (SigningKey, error) {
+	key, ok := k.keys[k.current]
+
+	if !ok {
+		return SigningKey{}, ErrNoSigningKey
+	}
+
+	return key, nil
+}
+
+func (k *KeyRing) Get(kid string) (SigningKey, error) {
+	key, ok := k.keys[kid]
+
+	if !ok {
+		return SigningKey{}, fmt.Errorf("%w [%s]", ErrUnknownKid, kid)
+	}
+
+	return key, nil
+}
+
+// JWKS публикует открытые части асимметричных ключей. HMAC-ключи не публикуются.
+func (k *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := k.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.Verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8 //nolint:mnd //bits to bytes
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,116 @@
+package sec
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, dir, name string, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestBuildKeyRing(t *testing.T) {
+	_, err := BuildKeyRing("", nil, "")
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+
+	_, err = BuildKeyRing("short", nil, "")
+	assert.ErrorIs(t, err, ErrWeakSecret)
+
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	files := map[string]string{
+		"rsa-1": writePEM(t, dir, "rsa", rsaKey),
+		"ec-1":  writePEM(t, dir, "ec", ecKey),
+		"ed-1":  writePEM(t, dir, "ed", edKey),
+	}
+
+	secret := strings.Repeat("s", MinSecretLen)
+
+	for _, kid := range []string{"rsa-1", "ec-1", "ed-1", ""} {
+		t.Run("sign with "+kid, func(t *testing.T) {
+			ring, err := BuildKeyRing(secret, files, kid)
+			require.NoError(t, err)
+
+			j := NewJwtProvider(time.Minute, time.Hour, ring)
+
+			token, err := j.GetJwtStr(42)
+			require.NoError(t, err)
+
+			claims, err := j.ParseJwt(token)
+			require.NoError(t, err)
+			assert.Equal(t, 42, claims.UserID)
+			assert.NotEmpty(t, claims.ID)
+		})
+	}
+
+	t.Run("rotation keeps old tokens valid", func(t *testing.T) {
+		old, err := BuildKeyRing(secret, files, "rsa-1")
+		require.NoError(t, err)
+
+		token, err := NewJwtProvider(time.Minute, time.Hour, old).GetJwtStr(7)
+		require.NoError(t, err)
+
+		rotated, err := BuildKeyRing(secret, files, "ed-1")
+		require.NoError(t, err)
+
+		claims, err := NewJwtProvider(time.Minute, time.Hour, rotated).ParseJwt(token)
+		require.NoError(t, err)
+		assert.Equal(t, 7, claims.UserID)
+	})
+
+	t.Run("algorithm is bound to kid", func(t *testing.T) {
+		ring, err := BuildKeyRing(secret, files, "")
+		require.NoError(t, err)
+
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: 1})
+		forged.Header["kid"] = "rsa-1"
+		token, err := forged.SignedString([]byte(secret))
+		require.NoError(t, err)
+
+		_, err = NewJwtProvider(time.Minute, time.Hour, ring).ParseJwt(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("jwks publishes only public keys", func(t *testing.T) {
+		ring, err := BuildKeyRing(secret, files, "")
+		require.NoError(t, err)
+
+		set := ring.JWKS()
+		require.Len(t, set.Keys, 3)
+
+		algs := map[string]string{}
+		for _, k := range set.Keys {
+			algs[k.Kid] = k.Alg
+		}
+		assert.Equal(t, map[string]string{"rsa-1": "RS256", "ec-1": "ES256", "ed-1": "EdDSA"}, algs)
+	})
+}
@@ -0,0 +1,147 @@
+package sec
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2idPrefix = "$argon2id$"
+
+	defArgonTime    = 2
+	defArgonMemory  = 64 * 1024
+	defArgonThreads = 2
+	defArgonKeyLen  = 32
+	defArgonSaltLen = 16
+	argonParts      = 6
+
+	// пределы параметров из сохранённого хеша: t=0 или p=0 роняют argon2.IDKey,
+	// а огромные m и длина ключа позволяют одной строке в БД исчерпать память
+	maxArgonTime   = 16
+	maxArgonMemory = 256 * 1024
+	minArgonKeyLen = 16
+	maxArgonKeyLen = 64
+)
+
+var (
+	ErrPasswordHashFormat = errors.New("INVALID PASSWORD HASH FORMAT")
+	ErrPasswordMismatch   = errors.New("PASSWORD MISMATCH")
+)
+
+// PasswordHasher хранит пароли в виде argon2id с параметрами, записанными в самой строке
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash), поэтому стоимость можно поднять,
+// не ломая уже сохранённые хеши.
+type PasswordHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func NewPasswordHasher() PasswordHasher {
+	return PasswordHasher{
+		Time:    defArgonTime,
+		Memory:  defArgonMemory,
+		Threads: defArgonThreads,
+		KeyLen:  defArgonKeyLen,
+		SaltLen: defArgonSaltLen,
+	}
+}
+
+func (h PasswordHasher) Hash(pass string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("CAN'T GENERATE SALT [%w]", err)
+	}
+
+	key := argon2.IDKey([]byte(pass), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.Memory,
+		h.Time,
+		h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify сравнивает пароль с сохранённым значением за постоянное время. Кроме argon2id
+// понимает bcrypt и пароли, сохранённые открытым текстом. needRehash сообщает, что
+// значение надо пересохранить текущими параметрами.
+func (h PasswordHasher) Verify(pass, encoded string) (needRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		return h.verifyArgon2id(pass, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(pass)); err != nil {
+			return false, ErrPasswordMismatch
+		}
+		return true, nil
+	default:
+		if subtle.ConstantTimeCompare([]byte(pass), []byte(encoded)) != 1 {
+			return false, ErrPasswordMismatch
+		}
+		return true, nil
+	}
+}
+
+func (h PasswordHasher) verifyArgon2id(pass, encoded string) (bool, error) {
+	var (
+		version, memory, time uint32
+		threads               uint8
+	)
+
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != argonParts {
+		return false, ErrPasswordHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrPasswordHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrPasswordHashFormat
+	}
+
+	if time < 1 || time > maxArgonTime || threads < 1 || memory < 8*uint32(threads) || memory > maxArgonMemory {
+		return false, ErrPasswordHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return false, ErrPasswordHashFormat
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil {
+		return false, ErrPasswordHashFormat
+	}
+
+	if len(key) < minArgonKeyLen || len(key) > maxArgonKeyLen {
+		return false, ErrPasswordHashFormat
+	}
+
+	testKey := argon2.IDKey([]byte(pass), salt, time, memory, threads, uint32(len(key)))
+
+	if subtle.ConstantTimeCompare(key, testKey) != 1 {
+		return false, ErrPasswordMismatch
+	}
+
+	needRehash := memory < h.Memory || time < h.Time || threads < h.Threads || uint32(len(key)) < h.KeyLen
+
+	return needRehash, nil
+}
@@ -0,0 +1,73 @@
+package sec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHasher_Verify(t *testing.T) {
+	h := NewPasswordHasher()
+
+	hash, err := h.Hash("!QAZ2wsx")
+	require.NoError(t, err)
+
+	weak := h
+	weak.Memory /= 2
+	weakHash, err := weak.Hash("!QAZ2wsx")
+	require.NoError(t, err)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("!QAZ2wsx"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		pass       string
+		encoded    string
+		needRehash bool
+		err        error
+	}{
+		{name: "Current argon2id hash", pass: "!QAZ2wsx", encoded: hash},
+		{name: "Wrong password", pass: "!QAZ2wsX", encoded: hash, err: ErrPasswordMismatch},
+		{name: "Weak argon2id hash", pass: "!QAZ2wsx", encoded: weakHash, needRehash: true},
+		{name: "Legacy bcrypt hash", pass: "!QAZ2wsx", encoded: string(bcryptHash), needRehash: true},
+		{name: "Legacy plaintext", pass: "!QAZ2wsx", encoded: "!QAZ2wsx", needRehash: true},
+		{name: "Legacy plaintext mismatch", pass: "!QAZ2wsx", encoded: "qwerty", err: ErrPasswordMismatch},
+		{name: "Broken hash", pass: "!QAZ2wsx", encoded: "$argon2id$v=19$broken", err: ErrPasswordHashFormat},
+		{name: "Zero time", pass: "!QAZ2wsx", encoded: withArgonParams(hash, "m=65536,t=0,p=2"), err: ErrPasswordHashFormat},
+		{name: "Zero threads", pass: "!QAZ2wsx", encoded: withArgonParams(hash, "m=65536,t=2,p=0"), err: ErrPasswordHashFormat},
+		{name: "Huge time", pass: "!QAZ2wsx", encoded: withArgonParams(hash, "m=65536,t=4294967295,p=2"), err: ErrPasswordHashFormat},
+		{name: "Huge memory", pass: "!QAZ2wsx", encoded: withArgonParams(hash, "m=4294967295,t=2,p=2"), err: ErrPasswordHashFormat},
+		{name: "Memory below threads", pass: "!QAZ2wsx", encoded: withArgonParams(hash, "m=8,t=2,p=2"), err: ErrPasswordHashFormat},
+		{name: "Empty key", pass: "!QAZ2wsx", encoded: withArgonKey(hash, ""), err: ErrPasswordHashFormat},
+		{name: "Huge key", pass: "!QAZ2wsx", encoded: withArgonKey(hash, strings.Repeat("A", 1<<20)), err: ErrPasswordHashFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needRehash, err := h.Verify(tt.pass, tt.encoded)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.needRehash, needRehash)
+		})
+	}
+}
+
+// withArgonParams подменяет в хеше argon2id параметры m, t и p.
+func withArgonParams(encoded, params string) string {
+	parts := strings.Split(encoded, "$")
+	parts[3] = params
+
+	return strings.Join(parts, "$")
+}
+
+// withArgonKey подменяет в хеше argon2id ключ.
+func withArgonKey(encoded, key string) string {
+	parts := strings.Split(encoded, "$")
+	parts[5] = key
+
+	return strings.Join(parts, "$")
+}
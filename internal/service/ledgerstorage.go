@@ -0,0 +1,787 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *StorageService) createAccrual(ctx context.Context, tx pgx.Tx, order models.POrder) (models.Opentry, error) {
+	ctx, span := s.span(ctx, "createAccrual", "INSERT", personAttr(order.Pid), orderAttr(order.Extnum))
+	defer span.End()
+
+	var acct string
+
+	err := tx.QueryRow(ctx, `SELECT acct FROM acct WHERE person=$1 AND sign=$2 ORDER BY id LIMIT 1`,
+		order.Pid,
+		AcctSidePassive).Scan(&acct)
+
+	if err != nil {
+		return models.Opentry{}, fmt.Errorf("CAN'T FIND PERSON ACCT FOR ACCRUAL [%w]", err)
+	}
+
+	opentry := models.Opentry{
+		Person:      order.Pid,
+		Porder:      order.ID,
+		OrderExtNum: order.Extnum,
+		Status:      Processed,
+		Opdate:      order.Updt,
+		Acctdb:      AcctAccrual,
+		Acctcr:      acct,
+		Sum1:        order.Accrual,
+		Crdt:        order.Updt,
+		Updt:        order.Updt,
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO opentry (person,porder,status,opdate,acctdb,acctcr,sum1,crdt,updt)
+									VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id`,
+		opentry.Person,
+		opentry.Porder,
+		opentry.Status,
+		opentry.Opdate,
+		opentry.Acctdb,
+		opentry.Acctcr,
+		opentry.Sum1,
+		opentry.Crdt,
+		opentry.Updt).Scan(&opentry.ID)
+
+	if err != nil {
+		return models.Opentry{}, fmt.Errorf("CAN'T INSERT ACCRUAL OPENTRY [%w]", err)
+	}
+
+	return opentry, nil
+}
+
+func (s *StorageService) getMoveByDb(ctx context.Context, q querier, acct string, opdate time.Time) ([]models.Opentry, error) { //nolint:stylecheck //It's debit neither DB
+	ctx, span := s.span(ctx, "getMoveByDb", "SELECT", acctAttr(acct))
+	defer span.End()
+
+	rows, err := q.Query(ctx, `SELECT opentry.id,
+    											opentry.person,
+    											opentry.porder,
+    											opentry.status,
+    											opentry.opdate,
+    											opentry.acctdb,
+    											opentry.acctcr,
+    											opentry.sum1,
+    											COALESCE(opentry.sum2,0),
+    											opentry.crdt,
+    											opentry.updt,
+												COALESCE(opentry.orderextnum,porder.extnum)
+	FROM  opentry
+	LEFT JOIN porder ON porder.id=opentry.porder
+	WHERE acctdb=$1 
+	AND opdate>=$2
+	ORDER BY opentry.crdt DESC`,
+		acct,
+		opdate)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T READ OPENTRY BY DB: [%w]", err)
+	}
+
+	defer rows.Close()
+
+	res := make([]models.Opentry, 0, DefSliceLength)
+	var status sql.NullString
+	var extNum sql.NullString
+
+	for rows.Next() {
+		opentry := models.Opentry{}
+		err := rows.Scan(
+			&opentry.ID,
+			&opentry.Person,
+			&opentry.Porder,
+			&status,
+			&opentry.Opdate,
+			&opentry.Acctdb,
+			&opentry.Acctcr,
+			&opentry.Sum1,
+			&opentry.Sum2,
+			&opentry.Crdt,
+			&opentry.Updt,
+			&extNum)
+
+		opentry.Status = status.String
+		opentry.OrderExtNum = extNum.String
+
+		if err != nil {
+			return nil, fmt.Errorf("CAN'T READ OPENTRY BY DB: [%w]", err)
+		}
+
+		res = append(res, opentry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("CAN'T READ OPENTRY BY DB: [%w]", err)
+	}
+
+	return res, nil
+}
+
+func (s *StorageService) getMoveByCr(ctx context.Context, q querier, acct string, opdate time.Time) ([]models.Opentry, error) {
+	ctx, span := s.span(ctx, "getMoveByCr", "SELECT", acctAttr(acct))
+	defer span.End()
+
+	rows, err := q.Query(ctx, `SELECT id,
+    											person,
+    											porder,
+    											status,
+    											opdate,
+    											acctdb,
+    											acctcr,
+    											sum1,
+    											COALESCE(sum2,0),
+    											crdt,
+    											updt
+	FROM  opentry
+	WHERE acctcr=$1 
+	AND opdate>=$2`,
+		acct,
+		opdate)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T READ OPENTRY BY CR: [%w]", err)
+	}
+
+	defer rows.Close()
+
+	res := make([]models.Opentry, 0, DefSliceLength)
+	var status sql.NullString
+
+	for rows.Next() {
+		opentry := models.Opentry{}
+		err := rows.Scan(
+			&opentry.ID,
+			&opentry.Person,
+			&opentry.Porder,
+			&status,
+			&opentry.Opdate,
+			&opentry.Acctdb,
+			&opentry.Acctcr,
+			&opentry.Sum1,
+			&opentry.Sum2,
+			&opentry.Crdt,
+			&opentry.Updt)
+
+		opentry.Status = status.String
+
+		if err != nil {
+			return nil, fmt.Errorf("CAN'T READ OPENTRY BY CR: [%w]", err)
+		}
+
+		res = append(res, opentry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("CAN'T READ OPENTRY BY CR: [%w]", err)
+	}
+
+	return res, nil
+}
+
+func (s *StorageService) getLastFixBalance(ctx context.Context, q querier, acct models.Acct) (models.AcctBal, error) {
+	ctx, span := s.span(ctx, "getLastFixBalance", "SELECT", acctAttr(acct.Acct))
+	defer span.End()
+
+	row := q.QueryRow(ctx, `SELECT id,
+											 person,
+											 opdate,
+											 acct,
+											 balance,
+											 db,
+											 cr,
+											 crdt,
+											 updt
+	                                  FROM acctbal 
+									  WHERE acct=$1 AND opdate>$2 AND opdate<=current_date
+									  ORDER BY opdate DESC LIMIT 1`,
+		acct.Acct,
+		acct.Crdt)
+
+	acctbal := models.AcctBal{}
+
+	err := row.Scan(
+		&acctbal.ID,
+		&acctbal.Person,
+		&acctbal.Opdate,
+		&acctbal.Acct,
+		&acctbal.Balance,
+		&acctbal.Db,
+		&acctbal.Cr,
+		&acctbal.Crdt,
+		&acctbal.Updt)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return models.AcctBal{}, fmt.Errorf("CAN'T READ ACCTBAL INFO [%w]", err)
+		}
+
+		return models.AcctBal{}, ErrNoFixedBalance
+	}
+
+	return acctbal, nil
+}
+
+func (s *StorageService) calcBalanceByAcct(ctx context.Context, q querier, acct models.Acct) (money.Money, error) {
+	ctx, span := s.span(ctx, "calcBalanceByAcct", "SELECT", acctAttr(acct.Acct))
+	defer span.End()
+
+	balance := money.Money(0)
+
+	acctbal, err := s.getLastFixBalance(ctx, q, acct)
+
+	if err != nil {
+		if !errors.Is(err, ErrNoFixedBalance) {
+			return 0, fmt.Errorf("CAN'T READ ACCTBAL INFO [%w]", err)
+		}
+	} else {
+		balance += acctbal.Balance
+	}
+
+	rows, err := s.getMoveByDb(ctx, q, acct.Acct, acctbal.Opdate)
+
+	if err != nil {
+		return 0, fmt.Errorf("CAN'T READ OPENTRY BY CR INFO [%w]", err)
+	}
+
+	for _, opentry := range rows {
+		if acct.Sign == AcctSideActive {
+			balance += opentry.Sum1
+		} else if acct.Sign == AcctSidePassive {
+			balance -= opentry.Sum1
+		}
+	}
+
+	rows, err = s.getMoveByCr(ctx, q, acct.Acct, acctbal.Opdate)
+
+	if err != nil {
+		return 0, fmt.Errorf("CAN'T READ OPENTRY BY DB INFO [%w]", err)
+	}
+
+	for _, opentry := range rows {
+		if acct.Sign == AcctSideActive {
+			balance -= opentry.Sum1
+		} else if acct.Sign == AcctSidePassive {
+			balance += opentry.Sum1
+		}
+	}
+
+	return balance, nil
+}
+
+// getPersonAccts с forUpdate блокирует счета клиента до конца транзакции q,
+// так что конкурирующие списания выполняются по очереди.
+func (s *StorageService) getPersonAccts(ctx context.Context, q querier, p models.Person, forUpdate bool) ([]models.Acct, error) {
+	ctx, span := s.span(ctx, "getPersonAccts", "SELECT", personAttr(p.ID))
+	defer span.End()
+
+	query := "SELECT id,acct,person,sign,status,crdt,updt FROM acct WHERE person=$1 ORDER BY id"
+
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(ctx, query, p.GetID())
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T FIND PERSON acct [%v]", err)
+	}
+
+	defer rows.Close()
+
+	res := make([]models.Acct, 0, DefSliceLength)
+
+	for rows.Next() {
+		var status, sign sql.NullString
+		acct := models.Acct{}
+		err := rows.Scan(&acct.ID, &acct.Acct, &acct.Person, &sign, &status, &acct.Crdt, &acct.Updt)
+
+		if err != nil {
+			return nil, fmt.Errorf("CAN'T CREATE ACCT STRUCT [%v]", err)
+		}
+
+		acct.Status = status.String
+		acct.Sign = sign.String
+		res = append(res, acct)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("CAN'T READ PERSON ACCTS [%v]", err)
+	}
+
+	return res, nil
+}
+
+func (s *StorageService) calcBalance(ctx context.Context, q querier, accts []models.Acct) (money.Money, error) {
+	ctx, span := s.span(ctx, "calcBalance", "SELECT")
+	defer span.End()
+
+	b := money.Money(0)
+
+	for _, acct := range accts {
+		b0, err := s.calcBalanceByAcct(ctx, q, acct)
+
+		if err != nil {
+			return 0, fmt.Errorf("CAN'T CALC BALANCE OF %s [%w]", acct.Acct, err)
+		}
+
+		b += b0
+	}
+
+	return b, nil
+}
+
+func (s *StorageService) GetBalance(ctx context.Context, p models.Person) (money.Money, error) {
+	ctx, span := s.span(ctx, "GetBalance", "SELECT", personAttr(p.ID))
+	defer span.End()
+
+	accts, err := s.getPersonAccts(ctx, s.pool, p, false)
+
+	if err != nil {
+		return 0, fmt.Errorf("CAN'T FIND PERSON ACCT [%v]", err)
+	}
+
+	return s.calcBalance(ctx, s.pool, accts)
+}
+
+func (s *StorageService) Getwithdrawn(ctx context.Context, p models.Person) (money.Money, error) {
+	ctx, span := s.span(ctx, "Getwithdrawn", "SELECT", personAttr(p.ID))
+	defer span.End()
+
+	b := money.Money(0)
+
+	accts, err := s.getPersonAccts(ctx, s.pool, p, false)
+
+	if err != nil {
+		return 0, fmt.Errorf("CAN'T GET PERSON ACCTS")
+	}
+
+	for _, acct := range accts {
+		fixedBalance, err := s.getLastFixBalance(ctx, s.pool, acct)
+
+		if err != nil {
+			if !errors.Is(err, ErrNoFixedBalance) {
+				return 0, fmt.Errorf("CAN'T CHECK LAST FIXED BALANCE: [%v]", err)
+			}
+		}
+
+		if acct.Sign == AcctSidePassive {
+			b += fixedBalance.Db
+			rows, err := s.getMoveByDb(ctx, s.pool, acct.Acct, fixedBalance.Opdate)
+
+			if err != nil {
+				return 0, fmt.Errorf("CAN'T GET ACCT MOBY BY DB: [%v]", err)
+			}
+			for _, opentry := range rows {
+				b += opentry.Sum1
+			}
+		} else if acct.Sign == AcctSideActive {
+			b += fixedBalance.Cr
+
+			rows, err := s.getMoveByCr(ctx, s.pool, acct.Acct, fixedBalance.Opdate)
+
+			if err != nil {
+				return 0, fmt.Errorf("CAN'T GET ACCT MOBY BY DB: [%v]", err)
+			}
+			for _, opentry := range rows {
+				b += opentry.Sum1
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// CreateWithdrawn списывает баллы в счёт заказа o. Счета клиента блокируются на время
+// транзакции, поэтому остаток проверяется и списывается атомарно. Непустой idemKey
+// делает запрос идемпотентным: повтор с тем же ключом возвращает исходный результат,
+// а повтор с тем же ключом, но другими заказом или суммой — ErrIdempotencyKeyReuse.
+func (s *StorageService) CreateWithdrawn(ctx context.Context, p models.Person, o models.POrder, sum money.Money, idemKey string) (models.Opentry, error) {
+	ctx, span := s.span(ctx, "CreateWithdrawn", "INSERT", personAttr(p.ID), orderAttr(o.Extnum))
+	defer span.End()
+
+	if sum <= 0 {
+		return models.Opentry{}, fmt.Errorf("ZERO SUM TO WITHDRAW")
+	}
+
+	var (
+		opentry     models.Opentry
+		withdrawErr error
+		replay      bool
+	)
+
+	// отказ из-за нехватки баллов тоже фиксируется, чтобы повтор с тем же ключом
+	// получил тот же ответ, поэтому он возвращается не из fn, а через withdrawErr
+	err := s.WithTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		opentry, withdrawErr, replay = models.Opentry{}, nil, false
+
+		accts, err := s.getPersonAccts(ctx, tx, p, true)
+
+		if err != nil {
+			return fmt.Errorf("CAN'T GET PERSON ACCT: [%w]", err)
+		}
+
+		if len(accts) == 0 {
+			return fmt.Errorf("NO ACTIVE ACCT FOR PERSON")
+		}
+
+		if idemKey != "" {
+			prev, err := s.getIdempotentWithdrawn(ctx, tx, p, o, sum, idemKey)
+
+			if !errors.Is(err, errNoIdempotencyKey) {
+				opentry, replay = prev, true
+				return err
+			}
+		}
+
+		opentry, withdrawErr = s.withdraw(ctx, tx, p, accts, o, sum)
+
+		if withdrawErr != nil && !errors.Is(withdrawErr, ErrRedSaldo) {
+			return withdrawErr
+		}
+
+		if idemKey != "" {
+			return s.saveIdempotencyKey(ctx, tx, p, o, sum, idemKey, opentry, withdrawErr)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return models.Opentry{}, err
+	}
+
+	if withdrawErr == nil && !replay {
+		s.Metrics.PointsWithdrawn(sum.Float64())
+	}
+
+	return opentry, withdrawErr
+}
+
+func (s *StorageService) withdraw(ctx context.Context,
+	tx pgx.Tx,
+	p models.Person,
+	accts []models.Acct,
+	o models.POrder,
+	sum money.Money) (models.Opentry, error) {
+	ctx, span := s.span(ctx, "withdraw", "INSERT", personAttr(p.ID), orderAttr(o.Extnum))
+	defer span.End()
+
+	balance, err := s.calcBalance(ctx, tx, accts)
+
+	if err != nil {
+		return models.Opentry{}, err
+	}
+
+	if sum > balance {
+		return models.Opentry{}, ErrRedSaldo
+	}
+
+	acct := accts[0]
+
+	opentry := models.Opentry{
+		Person:      p.GetID(),
+		Porder:      o.ID,
+		Status:      StatusNew,
+		OrderExtNum: o.Extnum,
+		Opdate:      time.Now(),
+		Sum1:        sum,
+		Crdt:        time.Now(),
+		Updt:        time.Now(),
+	}
+
+	if acct.Sign == AcctSidePassive {
+		opentry.Acctdb = acct.Acct
+		opentry.Acctcr = AcctSettlement
+	} else if acct.Sign == AcctSideActive {
+		opentry.Acctcr = acct.Acct
+		opentry.Acctdb = AcctSettlement
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO opentry (person,porder,orderextnum,status,opdate,acctdb,acctcr,sum1,crdt,updt) 
+	                                 VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id`,
+		opentry.Person,
+		opentry.Porder,
+		opentry.OrderExtNum,
+		opentry.Status,
+		opentry.Opdate,
+		opentry.Acctdb,
+		opentry.Acctcr,
+		opentry.Sum1,
+		opentry.Crdt,
+		opentry.Updt).
+		Scan(&opentry.ID)
+
+	if err != nil {
+		return models.Opentry{}, fmt.Errorf("CAN'T INSERT OPENTRY [%w]", err)
+	}
+
+	return opentry, nil
+}
+
+func (s *StorageService) getIdempotentWithdrawn(ctx context.Context,
+	tx pgx.Tx,
+	p models.Person,
+	o models.POrder,
+	sum money.Money,
+	idemKey string) (models.Opentry, error) {
+	ctx, span := s.span(ctx, "getIdempotentWithdrawn", "SELECT", personAttr(p.ID), orderAttr(o.Extnum))
+	defer span.End()
+
+	var (
+		extnum    string
+		sum1      money.Money
+		result    string
+		opentryID sql.NullInt64
+		opentry   models.Opentry
+	)
+
+	err := tx.QueryRow(ctx, `SELECT extnum,sum1,result,opentry FROM idempotency WHERE pid=$1 AND idemkey=$2`,
+		p.GetID(),
+		idemKey).Scan(&extnum, &sum1, &result, &opentryID)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return opentry, errNoIdempotencyKey
+		}
+		return opentry, fmt.Errorf("CAN'T READ IDEMPOTENCY KEY [%w]", err)
+	}
+
+	if extnum != o.Extnum || sum1 != sum {
+		return opentry, ErrIdempotencyKeyReuse
+	}
+
+	if result == idemResultRedSaldo {
+		return opentry, ErrRedSaldo
+	}
+
+	err = tx.QueryRow(ctx, `SELECT id,person,COALESCE(porder,0),COALESCE(orderextnum,''),opdate,acctdb,acctcr,sum1,crdt,updt
+								   FROM opentry WHERE id=$1`, opentryID.Int64).
+		Scan(&opentry.ID,
+			&opentry.Person,
+			&opentry.Porder,
+			&opentry.OrderExtNum,
+			&opentry.Opdate,
+			&opentry.Acctdb,
+			&opentry.Acctcr,
+			&opentry.Sum1,
+			&opentry.Crdt,
+			&opentry.Updt)
+
+	if err != nil {
+		return opentry, fmt.Errorf("CAN'T READ IDEMPOTENT OPENTRY [%w]", err)
+	}
+
+	return opentry, nil
+}
+
+func (s *StorageService) saveIdempotencyKey(ctx context.Context,
+	tx pgx.Tx,
+	p models.Person,
+	o models.POrder,
+	sum money.Money,
+	idemKey string,
+	opentry models.Opentry,
+	withdrawErr error) error {
+	ctx, span := s.span(ctx, "saveIdempotencyKey", "INSERT", personAttr(p.ID), orderAttr(o.Extnum))
+	defer span.End()
+
+	result := idemResultOK
+	opentryID := sql.NullInt64{Int64: int64(opentry.ID), Valid: opentry.ID != 0}
+
+	if errors.Is(withdrawErr, ErrRedSaldo) {
+		result = idemResultRedSaldo
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO idempotency (pid,idemkey,extnum,sum1,result,opentry,crdt) VALUES($1,$2,$3,$4,$5,$6,$7)`,
+		p.GetID(),
+		idemKey,
+		o.Extnum,
+		sum,
+		result,
+		opentryID,
+		time.Now())
+
+	if err != nil {
+		return fmt.Errorf("CAN'T SAVE IDEMPOTENCY KEY [%w]", err)
+	}
+
+	return nil
+}
+
+func (s *StorageService) GetWithdrawals(ctx context.Context, p models.Person) ([]models.Opentry, error) {
+	ctx, span := s.span(ctx, "GetWithdrawals", "SELECT", personAttr(p.ID))
+	defer span.End()
+
+	accts, err := s.getPersonAccts(ctx, s.pool, p, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T FIND PERSON ACCT [%v]", err)
+	}
+
+	rows := make([]models.Opentry, 0, DefSliceLength)
+
+	for _, acct := range accts {
+		if acct.Sign == AcctSidePassive {
+			r, e := s.getMoveByDb(ctx, s.pool, acct.Acct, acct.Crdt)
+
+			if e != nil {
+				return nil, fmt.Errorf("ACCT IS PASSIVE AND CAN'T GET MOVE BY DB [%v]", e)
+			}
+
+			rows = append(rows, r...)
+		} else if acct.Sign == AcctSideActive {
+			r, e := s.getMoveByCr(ctx, s.pool, acct.Acct, acct.Crdt)
+
+			if e != nil {
+				return nil, fmt.Errorf("ACCT IS ACTIVE AND CAN'T GET MOVE BY DB [%v]", e)
+			}
+
+			rows = append(rows, r...)
+		}
+	}
+
+	return rows, nil
+}
+
+// BalanceFix — остаток счёта до и после пересчёта зафиксированного сальдо.
+// Расхождение Before и After означает, что прежнее сальдо в acctbal было неверным.
+type BalanceFix struct {
+	Acct   string
+	Person int
+	Before money.Money
+	After  money.Money
+}
+
+// RecalcBalances заново фиксирует сальдо всех счетов клиентов на дату opdate по
+// проводкам до этой даты. Зафиксированные на opdate и позже сальдо удаляются.
+// Дата позже сегодняшней отклоняется: сальдо из будущего скрыло бы сегодняшние проводки.
+// Каждый счёт пересчитывается в своей транзакции RepeatableRead под блокировкой:
+// списания во время пересчёта не теряются, а остатки до и после читаются из
+// одного снимка.
+func (s *StorageService) RecalcBalances(ctx context.Context, opdate time.Time) ([]BalanceFix, error) {
+	ctx, span := s.span(ctx, "RecalcBalances", "UPDATE")
+	defer span.End()
+
+	opdate = time.Date(opdate.Year(), opdate.Month(), opdate.Day(), 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	if opdate.After(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+		return nil, fmt.Errorf("%w: %s", ErrRecalcInFuture, opdate.Format(time.DateOnly))
+	}
+
+	rows, err := s.pool.Query(ctx, "SELECT id,acct,person,sign,crdt FROM acct WHERE crdt<$1 ORDER BY id", opdate)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T READ ACCTS [%w]", err)
+	}
+
+	accts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Acct, error) {
+		var sign sql.NullString
+		acct := models.Acct{}
+		err := row.Scan(&acct.ID, &acct.Acct, &acct.Person, &sign, &acct.Crdt)
+		acct.Sign = sign.String
+		return acct, err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T READ ACCTS [%w]", err)
+	}
+
+	res := make([]BalanceFix, 0, len(accts))
+
+	for _, acct := range accts {
+		fix, err := s.recalcBalance(ctx, acct, opdate)
+
+		if err != nil {
+			return res, err
+		}
+
+		res = append(res, fix)
+	}
+
+	return res, nil
+}
+
+func (s *StorageService) recalcBalance(ctx context.Context, acct models.Acct, opdate time.Time) (BalanceFix, error) {
+	ctx, span := s.span(ctx, "recalcBalance", "UPDATE", acctAttr(acct.Acct))
+	defer span.End()
+
+	fix := BalanceFix{Acct: acct.Acct, Person: acct.Person}
+
+	err := s.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead}, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT id FROM acct WHERE id=$1 FOR UPDATE", acct.ID); err != nil {
+			return fmt.Errorf("CAN'T LOCK ACCT [%w]", err)
+		}
+
+		before, err := s.calcBalanceByAcct(ctx, tx, acct)
+
+		if err != nil {
+			return err
+		}
+
+		var db, cr money.Money //nolint:stylecheck //It's debit neither DB
+
+		// SUM(BIGINT) возвращает NUMERIC, который money.Money не читает
+		err = tx.QueryRow(ctx, `SELECT COALESCE(SUM(sum1) FILTER (WHERE acctdb=$1),0)::BIGINT,
+									   COALESCE(SUM(sum1) FILTER (WHERE acctcr=$1),0)::BIGINT
+								FROM opentry
+								WHERE (acctdb=$1 OR acctcr=$1) AND opdate<$2`,
+			acct.Acct,
+			opdate).Scan(&db, &cr)
+
+		if err != nil {
+			return fmt.Errorf("CAN'T SUM OPENTRY [%w]", err)
+		}
+
+		balance := db - cr
+
+		if acct.Sign == AcctSidePassive {
+			balance = cr - db
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM acctbal WHERE acct=$1 AND opdate>=$2", acct.Acct, opdate); err != nil {
+			return fmt.Errorf("CAN'T DELETE ACCTBAL [%w]", err)
+		}
+
+		now := time.Now()
+
+		_, err = tx.Exec(ctx, `INSERT INTO acctbal (person,opdate,acct,balance,db,cr,crdt,updt) VALUES($1,$2,$3,$4,$5,$6,$7,$8)`,
+			acct.Person,
+			opdate,
+			acct.Acct,
+			balance,
+			db,
+			cr,
+			now,
+			now)
+
+		if err != nil {
+			return fmt.Errorf("CAN'T INSERT ACCTBAL [%w]", err)
+		}
+
+		after, err := s.calcBalanceByAcct(ctx, tx, acct)
+
+		if err != nil {
+			return err
+		}
+
+		fix.Before = before
+		fix.After = after
+
+		return nil
+	})
+
+	if err != nil {
+		return BalanceFix{}, fmt.Errorf("CAN'T RECALC BALANCE OF %s [%w]", acct.Acct, err)
+	}
+
+	return fix, nil
+}
@@ -0,0 +1,621 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/metrics"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/DmitryM7/yapr56.git/internal/sec"
+)
+
+type (
+	// MemStorage хранит всё в памяти процесса и повторяет семантику StorageService:
+	// уникальные логины и номера заказов, проводки по счёту клиента, идемпотентные
+	// списания и ротацию refresh-токенов. Выбирается пустым DSN; данные теряются
+	// при перезапуске.
+	MemStorage struct {
+		log       logger.Lg
+		Passwords sec.PasswordHasher
+		Metrics   IMetrics
+		dummyHash string
+
+		mu       sync.Mutex
+		persons  map[uint]models.Person
+		logins   map[string]uint
+		accts    map[uint]string
+		orders   map[uint]models.POrder
+		extnums  map[string]uint
+		entries  []models.Opentry
+		idem     map[memIdemKey]memIdem
+		refresh  map[string]models.RefreshToken
+		revoked  map[string]time.Time
+		requests []models.Request
+		lastID   uint
+	}
+
+	memIdemKey struct {
+		pid uint
+		key string
+	}
+
+	memIdem struct {
+		extnum  string
+		sum     money.Money
+		result  string
+		opentry uint
+	}
+)
+
+func NewMemStorage(log logger.Lg) (*MemStorage, error) {
+	s := &MemStorage{
+		log:       log,
+		Passwords: sec.NewPasswordHasher(),
+		Metrics:   metrics.Nop{},
+		persons:   map[uint]models.Person{},
+		logins:    map[string]uint{},
+		accts:     map[uint]string{},
+		orders:    map[uint]models.POrder{},
+		extnums:   map[string]uint{},
+		idem:      map[memIdemKey]memIdem{},
+		refresh:   map[string]models.RefreshToken{},
+		revoked:   map[string]time.Time{},
+	}
+
+	dummyHash, err := s.Passwords.Hash("")
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T PREPARE PASSWORD HASHER [%w]", err)
+	}
+
+	s.dummyHash = dummyHash
+
+	return s, nil
+}
+
+// nextID выдаёт идентификаторы, общие для всех сущностей. Вызывается под mu.
+func (s *MemStorage) nextID() uint {
+	s.lastID++
+	return s.lastID
+}
+
+func (s *MemStorage) Close() error {
+	return nil
+}
+
+func (s *MemStorage) Ping(context.Context) error {
+	return nil
+}
+
+func (s *MemStorage) PendingMigrations(context.Context) (int, error) {
+	return 0, nil
+}
+
+func (s *MemStorage) GetPesonByCredential(_ context.Context, login, pass string) (models.Person, error) {
+	s.mu.Lock()
+	person, ok := s.persons[s.logins[login]]
+	s.mu.Unlock()
+
+	if !ok {
+		_, _ = s.Passwords.Verify(pass, s.dummyHash)
+		return models.Person{}, ErrUserCredentialInvalid
+	}
+
+	needRehash, err := s.Passwords.Verify(pass, person.Pass)
+
+	if err != nil {
+		return models.Person{}, ErrUserCredentialInvalid
+	}
+
+	if person.Status == PersonBlocked {
+		return models.Person{}, ErrUserBlocked
+	}
+
+	if needRehash {
+		hash, err := s.Passwords.Hash(pass)
+
+		if err != nil {
+			s.log.Warnln("CAN'T REHASH PASSWORD FOR PERSON", person.ID, err)
+			return person, nil
+		}
+
+		s.mu.Lock()
+		if p, ok := s.persons[person.ID]; ok && p.Pass == person.Pass {
+			p.Pass, p.Updt = hash, time.Now()
+			s.persons[p.ID] = p
+		}
+		s.mu.Unlock()
+	}
+
+	return person, nil
+}
+
+func (s *MemStorage) CreatePeson(_ context.Context, p models.Person) (models.Person, error) {
+	hash, err := s.Passwords.Hash(p.Pass)
+
+	if err != nil {
+		return p, fmt.Errorf("CAN'T HASH PASSWORD: [%w]", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.logins[p.Login]; ok {
+		return p, ErrUserExists
+	}
+
+	p.ID = s.nextID()
+	p.Pass = hash
+	p.Crdt = time.Now()
+	p.Updt = p.Crdt
+
+	s.persons[p.ID] = p
+	s.logins[p.Login] = p.ID
+	s.accts[p.ID] = `408178101` + fmt.Sprintf("%011d", p.ID)
+
+	return p, nil
+}
+
+func (s *MemStorage) GetPersonByID(_ context.Context, id int) (models.Person, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	person, ok := s.persons[uint(id)]
+
+	if !ok {
+		return person, sql.ErrNoRows
+	}
+
+	if person.Status == PersonBlocked {
+		return person, ErrUserBlocked
+	}
+
+	return person, nil
+}
+
+func (s *MemStorage) BlockPerson(_ context.Context, login string) (models.Person, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	person, ok := s.persons[s.logins[login]]
+
+	if !ok {
+		return models.Person{}, ErrUserNotFound
+	}
+
+	person.Status = PersonBlocked
+	person.Updt = time.Now()
+	s.persons[person.ID] = person
+
+	for hash, t := range s.refresh {
+		if t.Person == person.ID && !t.Revoked {
+			t.Revoked = true
+			t.Updt = person.Updt
+			s.refresh[hash] = t
+		}
+	}
+
+	return person, nil
+}
+
+func (s *MemStorage) CreateOrder(_ context.Context, p models.Person, order models.POrder) (models.POrder, error) {
+	if err := CheckLuhn(order.Extnum); err != nil {
+		return order, fmt.Errorf("NO VALID LUHN [%w]", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.extnums[order.Extnum]; ok {
+		if s.orders[id].Pid == p.GetID() {
+			return order, ErrDublicateOrder
+		}
+		return order, ErrOrderExists
+	}
+
+	order.ID = s.nextID()
+	order.Pid = p.GetID()
+	order.Status = StatusNew
+	order.Crdt = time.Now()
+	order.Updt = order.Crdt
+
+	s.orders[order.ID] = order
+	s.extnums[order.Extnum] = order.ID
+
+	return order, nil
+}
+
+func (s *MemStorage) GetOrder(_ context.Context, order models.POrder) (models.POrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.extnums[order.Extnum]
+
+	if !ok {
+		return order, sql.ErrNoRows
+	}
+
+	return s.orders[id], nil
+}
+
+func (s *MemStorage) GetOrders(_ context.Context, p models.Person) ([]models.POrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.POrder{}
+
+	for _, order := range s.orders {
+		if order.Pid == p.GetID() {
+			result = append(result, order)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.POrder) int {
+		if c := b.Crdt.Compare(a.Crdt); c != 0 {
+			return c
+		}
+		return int(b.ID) - int(a.ID)
+	})
+
+	return result, nil
+}
+
+func (s *MemStorage) GetOrdersByStatus(_ context.Context, limit int, statuses ...string) ([]models.POrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.POrder{}
+
+	for _, order := range s.orders {
+		if slices.Contains(statuses, order.Status) {
+			result = append(result, order)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.POrder) int {
+		if c := a.Updt.Compare(b.Updt); c != 0 {
+			return c
+		}
+		return int(a.ID) - int(b.ID)
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+// UpdateOrder ведёт себя как StorageService.UpdateOrder: заказ в окончательном
+// статусе не меняется, а переход в PROCESSED с начислением проводит его по счёту.
+func (s *MemStorage) UpdateOrder(_ context.Context, order models.POrder) error {
+	s.mu.Lock()
+
+	stored, ok := s.orders[order.ID]
+
+	if !ok || stored.Status == Invalid || stored.Status == Processed {
+		s.mu.Unlock()
+		return nil
+	}
+
+	stored.Status = order.Status
+	stored.Accrual = order.Accrual
+	stored.Updt = time.Now()
+	s.orders[stored.ID] = stored
+
+	accrued := stored.Status == Processed && stored.Accrual > 0
+
+	if accrued {
+		s.entries = append(s.entries, models.Opentry{
+			ID:          s.nextID(),
+			Person:      stored.Pid,
+			Porder:      stored.ID,
+			OrderExtNum: stored.Extnum,
+			Status:      Processed,
+			Opdate:      stored.Updt,
+			Acctdb:      AcctAccrual,
+			Acctcr:      s.accts[stored.Pid],
+			Sum1:        stored.Accrual,
+			Crdt:        stored.Updt,
+			Updt:        stored.Updt,
+		})
+	}
+
+	s.mu.Unlock()
+
+	if accrued {
+		s.Metrics.PointsIssued(stored.Accrual.Float64())
+	}
+
+	return nil
+}
+
+func (s *MemStorage) CountOrdersByStatus(context.Context) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := map[string]int{}
+
+	for _, order := range s.orders {
+		result[order.Status]++
+	}
+
+	return result, nil
+}
+
+// balance считает остаток пассивного счёта клиента: кредит минус дебет.
+// Вызывается под mu.
+func (s *MemStorage) balance(pid uint) money.Money {
+	acct := s.accts[pid]
+	b := money.Money(0)
+
+	for _, e := range s.entries {
+		if e.Acctcr == acct {
+			b += e.Sum1
+		}
+		if e.Acctdb == acct {
+			b -= e.Sum1
+		}
+	}
+
+	return b
+}
+
+func (s *MemStorage) GetBalance(_ context.Context, p models.Person) (money.Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.balance(p.GetID()), nil
+}
+
+func (s *MemStorage) Getwithdrawn(_ context.Context, p models.Person) (money.Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acct := s.accts[p.GetID()]
+	b := money.Money(0)
+
+	for _, e := range s.entries {
+		if e.Acctdb == acct {
+			b += e.Sum1
+		}
+	}
+
+	return b, nil
+}
+
+func (s *MemStorage) GetWithdrawals(_ context.Context, p models.Person) ([]models.Opentry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acct, ok := s.accts[p.GetID()]
+	rows := make([]models.Opentry, 0, DefSliceLength)
+
+	if !ok {
+		return rows, nil
+	}
+
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Acctdb == acct {
+			rows = append(rows, s.entries[i])
+		}
+	}
+
+	return rows, nil
+}
+
+// CreateWithdrawn повторяет StorageService.CreateWithdrawn, включая идемпотентность
+// по idemKey; атомарность обеспечивает общий мьютекс.
+func (s *MemStorage) CreateWithdrawn(_ context.Context, p models.Person, o models.POrder, sum money.Money, idemKey string) (models.Opentry, error) {
+	if sum <= 0 {
+		return models.Opentry{}, fmt.Errorf("ZERO SUM TO WITHDRAW")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acct, ok := s.accts[p.GetID()]
+
+	if !ok {
+		return models.Opentry{}, fmt.Errorf("NO ACTIVE ACCT FOR PERSON")
+	}
+
+	key := memIdemKey{pid: p.GetID(), key: idemKey}
+
+	if rec, ok := s.idem[key]; ok {
+		if rec.extnum != o.Extnum || rec.sum != sum {
+			return models.Opentry{}, ErrIdempotencyKeyReuse
+		}
+
+		if rec.result == idemResultRedSaldo {
+			return models.Opentry{}, ErrRedSaldo
+		}
+
+		for _, e := range s.entries {
+			if e.ID == rec.opentry {
+				return e, nil
+			}
+		}
+
+		return models.Opentry{}, fmt.Errorf("CAN'T READ IDEMPOTENT OPENTRY [%d]", rec.opentry)
+	}
+
+	var (
+		opentry models.Opentry
+		err     error
+	)
+
+	if sum > s.balance(p.GetID()) {
+		err = ErrRedSaldo
+	} else {
+		now := time.Now()
+
+		opentry = models.Opentry{
+			ID:          s.nextID(),
+			Person:      p.GetID(),
+			Porder:      o.ID,
+			Status:      StatusNew,
+			OrderExtNum: o.Extnum,
+			Opdate:      now,
+			Acctdb:      acct,
+			Acctcr:      AcctSettlement,
+			Sum1:        sum,
+			Crdt:        now,
+			Updt:        now,
+		}
+
+		s.entries = append(s.entries, opentry)
+	}
+
+	if idemKey != "" {
+		rec := memIdem{extnum: o.Extnum, sum: sum, result: idemResultOK, opentry: opentry.ID}
+
+		if errors.Is(err, ErrRedSaldo) {
+			rec.result = idemResultRedSaldo
+		}
+
+		s.idem[key] = rec
+	}
+
+	if err == nil {
+		s.Metrics.PointsWithdrawn(sum.Float64())
+	}
+
+	return opentry, err
+}
+
+func (s *MemStorage) SaveRefreshToken(_ context.Context, t models.RefreshToken) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.ID = s.nextID()
+	t.Crdt = time.Now()
+	t.Updt = t.Crdt
+
+	s.refresh[t.Hash] = t
+
+	return t, nil
+}
+
+// RotateRefreshToken повторяет StorageService.RotateRefreshToken: повторное
+// предъявление использованного токена отзывает всё семейство.
+func (s *MemStorage) RotateRefreshToken(_ context.Context, oldHash string, next models.RefreshToken) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.refresh[oldHash]
+
+	if !ok {
+		return next, ErrRefreshTokenInvalid
+	}
+
+	if old.Used && !old.Revoked {
+		s.revokeRefreshFamily(old.Family)
+		return next, ErrRefreshTokenReused
+	}
+
+	if !old.IsActive() {
+		return next, ErrRefreshTokenInvalid
+	}
+
+	now := time.Now()
+
+	old.Used = true
+	old.Updt = now
+	s.refresh[oldHash] = old
+
+	next.ID = s.nextID()
+	next.Person = old.Person
+	next.Family = old.Family
+	next.Crdt = now
+	next.Updt = now
+
+	s.refresh[next.Hash] = next
+
+	return next, nil
+}
+
+func (s *MemStorage) RevokeRefreshToken(_ context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.refresh[hash]; ok {
+		s.revokeRefreshFamily(t.Family)
+	}
+
+	return nil
+}
+
+// revokeRefreshFamily вызывается под mu.
+func (s *MemStorage) revokeRefreshFamily(family string) {
+	now := time.Now()
+
+	for hash, t := range s.refresh {
+		if t.Family == family {
+			t.Revoked = true
+			t.Updt = now
+			s.refresh[hash] = t
+		}
+	}
+}
+
+func (s *MemStorage) RevokeToken(_ context.Context, jti string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revoked[jti]; !ok {
+		s.revoked[jti] = expires
+	}
+
+	now := time.Now()
+
+	for id, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemStorage) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[jti]
+
+	return ok, nil
+}
+
+func (s *MemStorage) SaveRequest(_ context.Context, req models.Request) (models.Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req.ID = s.nextID()
+	req.Crdt = time.Now()
+	req.Updt = req.Crdt
+
+	s.requests = append(s.requests, req)
+
+	return req, nil
+}
+
+func (s *MemStorage) PurgeRequests(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.requests)
+
+	s.requests = slices.DeleteFunc(s.requests, func(r models.Request) bool {
+		return r.Crdt.Before(before)
+	})
+
+	return int64(n - len(s.requests)), nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMem(t *testing.T) *MemStorage {
+	t.Helper()
+
+	s, err := NewMemStorage(logger.NewLg())
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestMemStorage_persons(t *testing.T) {
+	ctx := context.Background()
+	s := newMem(t)
+
+	p, err := s.CreatePeson(ctx, models.Person{Login: "user", Pass: "secret12"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "secret12", p.Pass, "password is hashed")
+
+	_, err = s.CreatePeson(ctx, models.Person{Login: "user", Pass: "other123"})
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	got, err := s.GetPesonByCredential(ctx, "user", "secret12")
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, got.ID)
+
+	_, err = s.GetPesonByCredential(ctx, "user", "wrong")
+	assert.ErrorIs(t, err, ErrUserCredentialInvalid)
+
+	_, err = s.GetPesonByCredential(ctx, "nobody", "secret12")
+	assert.ErrorIs(t, err, ErrUserCredentialInvalid)
+}
+
+func TestMemStorage_BlockPerson(t *testing.T) {
+	ctx := context.Background()
+	s := newMem(t)
+
+	p, err := s.CreatePeson(ctx, models.Person{Login: "user", Pass: "secret12"})
+	require.NoError(t, err)
+
+	_, err = s.SaveRefreshToken(ctx, models.RefreshToken{Person: p.ID, Family: "f1", Hash: "h1", Expires: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	_, err = s.BlockPerson(ctx, "nobody")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	blocked, err := s.BlockPerson(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, PersonBlocked, blocked.Status)
+
+	_, err = s.GetPesonByCredential(ctx, "user", "secret12")
+	assert.ErrorIs(t, err, ErrUserBlocked)
+
+	_, err = s.GetPesonByCredential(ctx, "user", "wrong")
+	assert.ErrorIs(t, err, ErrUserCredentialInvalid, "blocked status is not revealed without password")
+
+	_, err = s.GetPersonByID(ctx, int(p.ID))
+	assert.ErrorIs(t, err, ErrUserBlocked)
+
+	_, err = s.RotateRefreshToken(ctx, "h1", models.RefreshToken{Hash: "h2", Expires: time.Now().Add(time.Hour)})
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+}
+
+func TestMemStorage_orders(t *testing.T) {
+	ctx := context.Background()
+	s := newMem(t)
+
+	alice, err := s.CreatePeson(ctx, models.Person{Login: "alice", Pass: "secret12"})
+	require.NoError(t, err)
+	bob, err := s.CreatePeson(ctx, models.Person{Login: "bob", Pass: "secret12"})
+	require.NoError(t, err)
+
+	_, err = s.CreateOrder(ctx, alice, models.POrder{Extnum: "12345678900"})
+	assert.ErrorIs(t, err, ErrNoLuhnNumber)
+
+	order, err := s.CreateOrder(ctx, alice, models.POrder{Extnum: "12345678903"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusNew, order.Status)
+
+	_, err = s.CreateOrder(ctx, alice, models.POrder{Extnum: "12345678903"})
+	assert.ErrorIs(t, err, ErrDublicateOrder)
+
+	_, err = s.CreateOrder(ctx, bob, models.POrder{Extnum: "12345678903"})
+	assert.ErrorIs(t, err, ErrOrderExists)
+
+	order.Status, order.Accrual = Processed, money.Money(1000)
+	require.NoError(t, s.UpdateOrder(ctx, order))
+	require.NoError(t, s.UpdateOrder(ctx, order), "final order isn't accrued twice")
+
+	balance, err := s.GetBalance(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, money.Money(1000), balance)
+
+	counts, err := s.CountOrdersByStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{Processed: 1}, counts)
+}
+
+func TestMemStorage_CreateWithdrawn(t *testing.T) {
+	ctx := context.Background()
+	s := newMem(t)
+
+	p, err := s.CreatePeson(ctx, models.Person{Login: "user", Pass: "secret12"})
+	require.NoError(t, err)
+
+	order, err := s.CreateOrder(ctx, p, models.POrder{Extnum: "12345678903"})
+	require.NoError(t, err)
+
+	order.Status, order.Accrual = Processed, money.Money(50000)
+	require.NoError(t, s.UpdateOrder(ctx, order))
+
+	first, err := s.CreateWithdrawn(ctx, p, models.POrder{Extnum: "2377225624"}, money.Money(20000), "key-1")
+	require.NoError(t, err)
+
+	again, err := s.CreateWithdrawn(ctx, p, models.POrder{Extnum: "2377225624"}, money.Money(20000), "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "same key returns the same withdrawal")
+
+	_, err = s.CreateWithdrawn(ctx, p, models.POrder{Extnum: "2377225624"}, money.Money(100), "key-1")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReuse)
+
+	_, err = s.CreateWithdrawn(ctx, p, models.POrder{Extnum: "2377225624"}, money.Money(40000), "key-2")
+	assert.ErrorIs(t, err, ErrRedSaldo)
+
+	_, err = s.CreateWithdrawn(ctx, p, models.POrder{Extnum: "2377225624"}, money.Money(40000), "key-2")
+	assert.ErrorIs(t, err, ErrRedSaldo, "refusal is remembered too")
+
+	balance, err := s.GetBalance(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, money.Money(30000), balance)
+
+	withdrawn, err := s.Getwithdrawn(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, money.Money(20000), withdrawn)
+
+	rows, err := s.GetWithdrawals(ctx, p)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "2377225624", rows[0].OrderExtNum)
+}
+
+func TestMemStorage_RotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	s := newMem(t)
+
+	expires := time.Now().Add(time.Hour)
+
+	_, err := s.SaveRefreshToken(ctx, models.RefreshToken{Person: 1, Family: "f", Hash: "h1", Expires: expires})
+	require.NoError(t, err)
+
+	next, err := s.RotateRefreshToken(ctx, "h1", models.RefreshToken{Hash: "h2", Expires: expires})
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), next.Person)
+
+	_, err = s.RotateRefreshToken(ctx, "h1", models.RefreshToken{Hash: "h3", Expires: expires})
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	_, err = s.RotateRefreshToken(ctx, "h2", models.RefreshToken{Hash: "h4", Expires: expires})
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid, "whole family is revoked")
+
+	require.NoError(t, s.RevokeToken(ctx, "jti", expires))
+
+	revoked, err := s.IsTokenRevoked(ctx, "jti")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// ErrNoMigrationToRollback — в БД нет применённых миграций для отката.
+var ErrNoMigrationToRollback = errors.New("NO APPLIED MIGRATION TO ROLL BACK")
+
+// MigrationState — состояние одной встроенной миграции.
+type MigrationState struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrator собирает goose.Provider над встроенными миграциями. С locked сессионная
+// блокировка не даёт двум экземплярам применять миграции одновременно; чтению
+// состояния она не нужна, иначе проверка готовности ждала бы чужую миграцию.
+func (s *StorageService) migrator(locked bool) (*goose.Provider, error) {
+	fsys, err := fs.Sub(embedMigrations, "migrations")
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T OPEN EMBEDDED MIGRATIONS [%w]", err)
+	}
+
+	var opts []goose.ProviderOption
+
+	if locked {
+		locker, err := lock.NewPostgresSessionLocker()
+
+		if err != nil {
+			return nil, fmt.Errorf("CAN'T CREATE MIGRATION LOCKER [%w]", err)
+		}
+
+		opts = append(opts, goose.WithSessionLocker(locker))
+	}
+
+	p, err := goose.NewProvider(goose.DialectPostgres, s.db, fsys, opts...)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T CREATE MIGRATION PROVIDER [%w]", err)
+	}
+
+	return p, nil
+}
+
+// MigrateUp применяет все ожидающие миграции и возвращает их версии.
+func (s *StorageService) MigrateUp(ctx context.Context) ([]int64, error) {
+	ctx, span := s.span(ctx, "MigrateUp", "MIGRATE")
+	defer span.End()
+
+	p, err := s.migrator(true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := p.Up(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T UP MIGRATIONS [%w]", err)
+	}
+
+	versions := make([]int64, 0, len(res))
+
+	for _, r := range res {
+		versions = append(versions, r.Source.Version)
+	}
+
+	return versions, nil
+}
+
+// MigrateDown откатывает последнюю применённую миграцию и возвращает её версию.
+func (s *StorageService) MigrateDown(ctx context.Context) (int64, error) {
+	ctx, span := s.span(ctx, "MigrateDown", "MIGRATE")
+	defer span.End()
+
+	p, err := s.migrator(true)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if s.migrated != nil {
+		s.migrated.Store(false)
+	}
+
+	res, err := p.Down(ctx)
+
+	if err != nil {
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			return 0, ErrNoMigrationToRollback
+		}
+		return 0, fmt.Errorf("CAN'T DOWN MIGRATION [%w]", err)
+	}
+
+	return res.Source.Version, nil
+}
+
+// MigrateRedo откатывает последнюю применённую миграцию и применяет её заново.
+func (s *StorageService) MigrateRedo(ctx context.Context) (int64, error) {
+	version, err := s.MigrateDown(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, span := s.span(ctx, "MigrateRedo", "MIGRATE")
+	defer span.End()
+
+	p, err := s.migrator(true)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := p.ApplyVersion(ctx, version, true); err != nil {
+		return 0, fmt.Errorf("CAN'T REAPPLY MIGRATION %d [%w]", version, err)
+	}
+
+	return version, nil
+}
+
+// MigrationStatus возвращает состояние встроенных миграций по возрастанию версий.
+func (s *StorageService) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	ctx, span := s.span(ctx, "MigrationStatus", "SELECT")
+	defer span.End()
+
+	p, err := s.migrator(false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := p.Status(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T GET MIGRATION STATUS [%w]", err)
+	}
+
+	res := make([]MigrationState, 0, len(status))
+
+	for _, st := range status {
+		res = append(res, MigrationState{
+			Version:   st.Source.Version,
+			Name:      path.Base(st.Source.Path),
+			Applied:   st.State == goose.StateApplied,
+			AppliedAt: st.AppliedAt,
+		})
+	}
+
+	return res, nil
+}
+
+// PendingMigrations возвращает число встроенных миграций, ещё не применённых к БД.
+// Его опрашивает /readyz, поэтому после первого нуля БД больше не читается: откат
+// миграций этим процессом сбрасывает признак, откат снаружи требует перезапуска.
+func (s *StorageService) PendingMigrations(ctx context.Context) (int, error) {
+	if s.migrated != nil && s.migrated.Load() {
+		return 0, nil
+	}
+
+	status, err := s.MigrationStatus(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+
+	for _, st := range status {
+		if !st.Applied {
+			pending++
+		}
+	}
+
+	if pending == 0 && s.migrated != nil {
+		s.migrated.Store(true)
+	}
+
+	return pending, nil
+}
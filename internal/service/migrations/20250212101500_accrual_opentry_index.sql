@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_opentry_accrual ON opentry (porder) WHERE acctdb = '70606810000000000001';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_opentry_accrual;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE opentry ADD COLUMN IF NOT EXISTS orderextnum NUMERIC(20,0);
+
+CREATE TABLE IF NOT EXISTS idempotency (
+    id SERIAL PRIMARY KEY,
+    pid INTEGER,
+    idemkey VARCHAR(255),
+    extnum NUMERIC(20,0),
+    sum1 INTEGER,
+    result VARCHAR(20),
+    opentry INTEGER,
+    crdt TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_idempotency_key ON idempotency (pid,idemkey);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE idempotency;
+ALTER TABLE opentry DROP COLUMN IF EXISTS orderextnum;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_token (
+    id SERIAL PRIMARY KEY,
+    person INTEGER,
+    family VARCHAR(64),
+    hash VARCHAR(64),
+    expires TIMESTAMP,
+    used BOOLEAN DEFAULT FALSE,
+    revoked BOOLEAN DEFAULT FALSE,
+    crdt TIMESTAMP,
+    updt TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_refresh_token_hash ON refresh_token (hash);
+CREATE INDEX idx_refresh_token_family ON refresh_token (family);
+
+CREATE TABLE IF NOT EXISTS revoked_token (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE refresh_token;
+DROP TABLE revoked_token;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE request ADD COLUMN reqid VARCHAR(128);
+ALTER TABLE request ADD COLUMN method VARCHAR(10);
+ALTER TABLE request ADD COLUMN path VARCHAR(255);
+ALTER TABLE request ADD COLUMN status INTEGER;
+
+CREATE INDEX idx_request_crdt ON request (crdt);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_request_crdt;
+
+ALTER TABLE request DROP COLUMN reqid;
+ALTER TABLE request DROP COLUMN method;
+ALTER TABLE request DROP COLUMN path;
+ALTER TABLE request DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- суммы хранятся в минорных единицах (1 балл = 100), прежние целые баллы умножаются на 100
+ALTER TABLE porder ALTER COLUMN accrual TYPE BIGINT USING accrual::BIGINT * 100;
+
+ALTER TABLE opentry ALTER COLUMN sum1 TYPE BIGINT USING sum1::BIGINT * 100;
+ALTER TABLE opentry ALTER COLUMN sum2 TYPE BIGINT USING sum2::BIGINT * 100;
+
+ALTER TABLE acctbal ALTER COLUMN balance TYPE BIGINT USING balance::BIGINT * 100;
+ALTER TABLE acctbal ALTER COLUMN db TYPE BIGINT USING db::BIGINT * 100;
+ALTER TABLE acctbal ALTER COLUMN cr TYPE BIGINT USING cr::BIGINT * 100;
+
+ALTER TABLE idempotency ALTER COLUMN sum1 TYPE BIGINT USING sum1::BIGINT * 100;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- копейки при откате округляются до целых баллов
+ALTER TABLE porder ALTER COLUMN accrual TYPE INTEGER USING round(accrual / 100.0);
+
+ALTER TABLE opentry ALTER COLUMN sum1 TYPE INTEGER USING round(sum1 / 100.0);
+ALTER TABLE opentry ALTER COLUMN sum2 TYPE INTEGER USING round(sum2 / 100.0);
+
+ALTER TABLE acctbal ALTER COLUMN balance TYPE INTEGER USING round(balance / 100.0);
+ALTER TABLE acctbal ALTER COLUMN db TYPE INTEGER USING round(db / 100.0);
+ALTER TABLE acctbal ALTER COLUMN cr TYPE INTEGER USING round(cr / 100.0);
+
+ALTER TABLE idempotency ALTER COLUMN sum1 TYPE INTEGER USING round(sum1 / 100.0);
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- номер заказа — строка цифр произвольной длины, NUMERIC(20,0) не вмещает длинные номера партнёров
+ALTER TABLE porder ALTER COLUMN extnum TYPE TEXT USING extnum::TEXT;
+ALTER TABLE opentry ALTER COLUMN orderextnum TYPE TEXT USING orderextnum::TEXT;
+ALTER TABLE idempotency ALTER COLUMN extnum TYPE TEXT USING extnum::TEXT;
+-- строка btree-индекса не длиннее ~2700 байт: уникальность держим по md5, поиск — по hash-индексу
+DROP INDEX idx_extnum;
+CREATE UNIQUE INDEX idx_extnum_md5 ON porder (md5(extnum));
+CREATE INDEX idx_extnum ON porder USING HASH (extnum);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- откат невозможен, если уже сохранены номера длиннее 20 цифр
+DROP INDEX idx_extnum;
+DROP INDEX idx_extnum_md5;
+ALTER TABLE porder ALTER COLUMN extnum TYPE NUMERIC(20,0) USING extnum::NUMERIC(20,0);
+ALTER TABLE opentry ALTER COLUMN orderextnum TYPE NUMERIC(20,0) USING orderextnum::NUMERIC(20,0);
+ALTER TABLE idempotency ALTER COLUMN extnum TYPE NUMERIC(20,0) USING extnum::NUMERIC(20,0);
+CREATE UNIQUE INDEX idx_extnum ON porder (extnum);
+-- +goose StatementEnd
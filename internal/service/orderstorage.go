@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *StorageService) CreateOrder(ctx context.Context, p models.Person, order models.POrder) (models.POrder, error) {
+	ctx, span := s.span(ctx, "CreateOrder", "INSERT", personAttr(p.ID), orderAttr(order.Extnum))
+	defer span.End()
+
+	err := CheckLuhn(order.Extnum)
+
+	if err != nil {
+		return order, fmt.Errorf("NO VALID LUHN [%w]", err)
+	}
+
+	order.Status = StatusNew
+
+	order.Pid = p.GetID()
+	order.Crdt = time.Now()
+	order.Updt = order.Crdt
+
+	err = s.pool.QueryRow(ctx, `INSERT INTO porder (pid,extnum,status,crdt,updt) VALUES($1,$2,$3,$4,$5) RETURNING id`,
+		order.Pid,
+		order.Extnum,
+		StatusNew,
+		order.Crdt,
+		order.Updt).Scan(&order.ID)
+
+	if err != nil {
+		if !isUniqueViolation(err) {
+			return order, fmt.Errorf("CAN'T CREATE ORDER [%w]", err)
+		}
+
+		dublicateOrder, err := s.GetOrder(ctx, order)
+
+		if err != nil {
+			return order, err
+		}
+
+		if dublicateOrder.GetPID() == order.Pid {
+			return order, ErrDublicateOrder
+		} else {
+			return order, ErrOrderExists
+		}
+	}
+
+	return order, nil
+}
+
+func (s *StorageService) GetOrder(ctx context.Context, order models.POrder) (models.POrder, error) {
+	ctx, span := s.span(ctx, "GetOrder", "SELECT", orderAttr(order.Extnum))
+	defer span.End()
+
+	var status sql.NullString
+
+	err := s.pool.QueryRow(ctx, "SELECT id,pid,extnum,status,crdt,updt FROM porder WHERE extnum=$1", order.Extnum).
+		Scan(&order.ID,
+			&order.Pid,
+			&order.Extnum,
+			&status,
+			&order.Crdt,
+			&order.Updt)
+
+	order.Status = status.String
+
+	if err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// collectOrders читает строки id,pid,extnum,status,accrual,crdt,updt и закрывает rows.
+func collectOrders(rows pgx.Rows) ([]models.POrder, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.POrder, error) {
+		var (
+			order  models.POrder
+			status sql.NullString
+		)
+
+		err := row.Scan(&order.ID,
+			&order.Pid,
+			&order.Extnum,
+			&status,
+			&order.Accrual,
+			&order.Crdt,
+			&order.Updt)
+
+		order.Status = status.String
+
+		return order, err
+	})
+}
+
+func (s *StorageService) GetOrders(ctx context.Context, p models.Person) ([]models.POrder, error) {
+	ctx, span := s.span(ctx, "GetOrders", "SELECT", personAttr(p.ID))
+	defer span.End()
+
+	rows, err := s.pool.Query(ctx, `SELECT id,pid,extnum,status,accrual,crdt,updt 
+										 FROM porder 
+										 WHERE pid=$1 
+										ORDER BY crdt DESC`, p.GetID())
+
+	if err != nil {
+		return []models.POrder{}, fmt.Errorf("CAN'T GET ORDERS [%w]", err)
+	}
+
+	result, err := collectOrders(rows)
+
+	if err != nil {
+		return []models.POrder{}, fmt.Errorf("CAN'T READ ORDERS [%w]", err)
+	}
+
+	return result, nil
+}
+
+func (s *StorageService) GetOrdersByStatus(ctx context.Context, limit int, statuses ...string) ([]models.POrder, error) {
+	ctx, span := s.span(ctx, "GetOrdersByStatus", "SELECT")
+	defer span.End()
+
+	rows, err := s.pool.Query(ctx, `SELECT id,pid,extnum,status,accrual,crdt,updt
+										 FROM porder
+										 WHERE status = ANY($1)
+										ORDER BY updt
+										LIMIT $2`, statuses, limit)
+
+	if err != nil {
+		return []models.POrder{}, fmt.Errorf("CAN'T GET ORDERS BY STATUS [%w]", err)
+	}
+
+	result, err := collectOrders(rows)
+
+	if err != nil {
+		return []models.POrder{}, fmt.Errorf("CAN'T READ ORDERS BY STATUS [%w]", err)
+	}
+
+	return result, nil
+}
+
+// UpdateOrder сохраняет статус заказа, полученный из системы расчёта. Если заказ
+// переходит в PROCESSED с начислением, в той же транзакции на счёт клиента
+// проводится начисление баллов. Заказ в окончательном статусе не меняется,
+// поэтому повторная обработка не приводит к двойному начислению.
+func (s *StorageService) UpdateOrder(ctx context.Context, order models.POrder) error {
+	ctx, span := s.span(ctx, "UpdateOrder", "UPDATE", orderAttr(order.Extnum))
+	defer span.End()
+
+	var accrued bool
+
+	err := s.WithTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		accrued = false
+		order.Updt = time.Now()
+
+		err := tx.QueryRow(ctx, `UPDATE porder SET status=$1,accrual=$2,updt=$3
+								   WHERE id=$4 AND status NOT IN ($5,$6)
+								   RETURNING pid`,
+			order.Status,
+			order.Accrual,
+			order.Updt,
+			order.ID,
+			Invalid,
+			Processed).Scan(&order.Pid)
+
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return fmt.Errorf("CAN'T UPDATE ORDER [%w]", err)
+		}
+
+		if order.Status == Processed && order.Accrual > 0 {
+			if _, err := s.createAccrual(ctx, tx, order); err != nil {
+				return err
+			}
+			accrued = true
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if accrued {
+		s.Metrics.PointsIssued(order.Accrual.Float64())
+	}
+
+	return nil
+}
+
+// CountOrdersByStatus возвращает число заказов в каждом статусе.
+func (s *StorageService) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	ctx, span := s.span(ctx, "CountOrdersByStatus", "SELECT")
+	defer span.End()
+
+	rows, err := s.pool.Query(ctx, "SELECT status,count(*) FROM porder GROUP BY status")
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T COUNT ORDERS [%w]", err)
+	}
+
+	defer rows.Close()
+
+	result := map[string]int{}
+
+	for rows.Next() {
+		var (
+			status string
+			count  int
+		)
+
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("CAN'T SCAN ORDERS COUNT [%w]", err)
+		}
+
+		result[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("CAN'T READ ORDERS COUNT [%w]", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetPesonByCredential ищет клиента по логину и проверяет пароль в Go, а не в SQL.
+// Пароль, сохранённый открытым текстом или устаревшим хешем, при успешном входе
+// пересохраняется текущими параметрами.
+func (s *StorageService) GetPesonByCredential(ctx context.Context, login, pass string) (models.Person, error) {
+	ctx, span := s.span(ctx, "GetPesonByCredential", "SELECT")
+	defer span.End()
+
+	person := models.Person{}
+
+	row := s.pool.QueryRow(ctx, "SELECT * FROM person WHERE login=$1", login)
+
+	var fullname, surname, name, status sql.NullString
+
+	err := row.Scan(&person.ID,
+		&person.Login,
+		&person.Pass,
+		&fullname,
+		&surname,
+		&name,
+		&status,
+		&person.Crdt,
+		&person.Updt)
+
+	person.Fullname = fullname.String
+	person.Surname = surname.String
+	person.Name = name.String
+	person.Status = status.String
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Считаем хеш и для несуществующего логина, чтобы время ответа его не выдавало.
+			_, _ = s.Passwords.Verify(pass, s.dummyHash)
+			return models.Person{}, ErrUserCredentialInvalid
+		}
+		return person, fmt.Errorf("CAN'T SEARCH PERSON BY CREDENTIAL [%w]", err)
+	}
+
+	needRehash, err := s.Passwords.Verify(pass, person.Pass)
+
+	if err != nil {
+		return models.Person{}, ErrUserCredentialInvalid
+	}
+
+	if person.Status == PersonBlocked {
+		return models.Person{}, ErrUserBlocked
+	}
+
+	if needRehash {
+		if err := s.rehashPassword(ctx, person, pass); err != nil {
+			s.log.Warnln("CAN'T REHASH PASSWORD FOR PERSON", person.ID, err)
+		}
+	}
+
+	return person, nil
+}
+
+func (s *StorageService) rehashPassword(ctx context.Context, p models.Person, pass string) error {
+	ctx, span := s.span(ctx, "rehashPassword", "UPDATE", personAttr(p.ID))
+	defer span.End()
+
+	hash, err := s.Passwords.Hash(pass)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.pool.Exec(ctx, "UPDATE person SET password=$1,updt=$2 WHERE id=$3 AND password=$4",
+		hash,
+		time.Now(),
+		p.ID,
+		p.Pass)
+
+	if err != nil {
+		return fmt.Errorf("CAN'T UPDATE PASSWORD [%w]", err)
+	}
+
+	return nil
+}
+
+// CreatePeson создаёт клиента и его лицевой счёт в одной транзакции.
+func (s *StorageService) CreatePeson(ctx context.Context, p models.Person) (models.Person, error) {
+	ctx, span := s.span(ctx, "CreatePeson", "INSERT")
+	defer span.End()
+
+	p.Crdt = time.Now()
+	p.Updt = p.Crdt
+
+	hash, err := s.Passwords.Hash(p.Pass)
+
+	if err != nil {
+		return p, fmt.Errorf("CAN'T HASH PASSWORD: [%w]", err)
+	}
+
+	var personID uint
+
+	err = s.WithTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var acctSerial int
+
+		err := tx.QueryRow(ctx, `INSERT INTO person (login,password,crdt,updt) VALUES($1,$2,$3,$4) RETURNING id`,
+			p.Login,
+			hash,
+			p.Crdt,
+			p.Updt).Scan(&personID)
+
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrUserExists
+			}
+			return fmt.Errorf("CAN'T CREATE PERSON [%w]", err)
+		}
+
+		if err := tx.QueryRow(ctx, `SELECT nextval('acctserial')`).Scan(&acctSerial); err != nil {
+			return fmt.Errorf("CAN'T GET ACCT SEQUENCE VALUE [%w]", err)
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO acct (acct,person,sign,crdt,updt) VALUES($1,$2,$3,$4,$5)`,
+			`408178101`+fmt.Sprintf("%011d", acctSerial),
+			personID,
+			AcctSidePassive,
+			p.Crdt,
+			p.Updt)
+
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrUserExists
+			}
+			return fmt.Errorf("CAN'T CREATE PERSON ACCT [%w]", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return models.Person{}, err
+	}
+
+	p.ID = personID
+	p.Pass = hash
+
+	return p, nil
+}
+
+func (s *StorageService) GetPersonByID(ctx context.Context, id int) (models.Person, error) {
+	ctx, span := s.span(ctx, "GetPersonByID", "SELECT", tracing.PersonID.Int(id))
+	defer span.End()
+
+	person := models.Person{}
+
+	row := s.pool.QueryRow(ctx, "SELECT * FROM person WHERE id=$1", id)
+
+	var fullname, surname, name, status sql.NullString
+
+	err := row.Scan(&person.ID,
+		&person.Login,
+		&person.Pass,
+		&fullname,
+		&surname,
+		&name,
+		&status,
+		&person.Crdt,
+		&person.Updt)
+
+	person.Fullname = fullname.String
+	person.Surname = surname.String
+	person.Name = name.String
+	person.Status = status.String
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return person, err
+		}
+		return person, fmt.Errorf("CAN'T SEARCH PERSON BY ID [%w]", err)
+	}
+
+	if person.Status == PersonBlocked {
+		return person, ErrUserBlocked
+	}
+
+	return person, nil
+}
+
+// BlockPerson блокирует клиента по логину и отзывает все его refresh-токены.
+// Выданные access-токены отклоняет middleware контроллера: для заблокированного
+// клиента GetPersonByID возвращает ErrUserBlocked.
+func (s *StorageService) BlockPerson(ctx context.Context, login string) (models.Person, error) {
+	ctx, span := s.span(ctx, "BlockPerson", "UPDATE")
+	defer span.End()
+
+	p := models.Person{Login: login, Status: PersonBlocked}
+
+	err := s.WithTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		p.Updt = time.Now()
+
+		err := tx.QueryRow(ctx, `UPDATE person SET status=$1,updt=$2 WHERE login=$3 RETURNING id`,
+			PersonBlocked,
+			p.Updt,
+			login).Scan(&p.ID)
+
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("CAN'T BLOCK PERSON [%w]", err)
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE refresh_token SET revoked=TRUE,updt=$1 WHERE person=$2 AND NOT revoked`,
+			p.Updt,
+			p.ID)
+
+		if err != nil {
+			return fmt.Errorf("CAN'T REVOKE PERSON REFRESH TOKENS [%w]", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return models.Person{}, err
+	}
+
+	return p, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/models"
+)
+
+// SaveRequest сохраняет запрос и ответ для аудита.
+func (s *StorageService) SaveRequest(ctx context.Context, req models.Request) (models.Request, error) {
+	ctx, span := s.span(ctx, "SaveRequest", "INSERT", personAttr(req.Pid))
+	defer span.End()
+
+	req.Crdt = time.Now()
+	req.Updt = req.Crdt
+
+	err := s.pool.QueryRow(ctx, `INSERT INTO request (pid,reqid,method,path,status,intext,outtext,crdt,updt)
+									  VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id`,
+		sql.NullInt64{Int64: int64(req.Pid), Valid: req.Pid != 0},
+		req.RequestID,
+		req.Method,
+		req.Path,
+		req.Status,
+		req.Intext,
+		req.Outtext,
+		req.Crdt,
+		req.Updt).Scan(&req.ID)
+
+	if err != nil {
+		return req, fmt.Errorf("CAN'T SAVE REQUEST [%w]", err)
+	}
+
+	return req, nil
+}
+
+// PurgeRequests удаляет записи аудита, созданные раньше before.
+func (s *StorageService) PurgeRequests(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := s.span(ctx, "PurgeRequests", "DELETE")
+	defer span.End()
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM request WHERE crdt<$1`, before)
+
+	if err != nil {
+		return 0, fmt.Errorf("CAN'T PURGE REQUESTS [%w]", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
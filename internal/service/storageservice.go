@@ -6,32 +6,72 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/DmitryM7/yapr56.git/internal/logger"
-	"github.com/DmitryM7/yapr56.git/internal/models"
-	"github.com/jackc/pgerrcode"
+	"github.com/DmitryM7/yapr56.git/internal/metrics"
+	"github.com/DmitryM7/yapr56.git/internal/sec"
+	"github.com/DmitryM7/yapr56.git/internal/tracing"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
 	ErrUserExists            = errors.New("USER EXISTS")
 	ErrUserCredentialInvalid = errors.New("USER CREDENTIAL INVALID")
+	ErrUserBlocked           = errors.New("USER BLOCKED")
+	ErrUserNotFound          = errors.New("USER NOT FOUND")
 	ErrNoLuhnNumber          = errors.New("LUHN CHECKSUMM ERROR")
 	ErrOrderExists           = errors.New("ORDER WITH NUMBER EXISTS")
 	ErrDublicateOrder        = errors.New("DUBLICATE ORDER")
 	ErrNoFixedBalance        = errors.New("NO FIXED BALANCE YET")
 	ErrRedSaldo              = errors.New("RED SALDO")
+	ErrRecalcInFuture        = errors.New("CAN'T RECALC BALANCE ON FUTURE DATE")
+	ErrIdempotencyKeyReuse   = errors.New("IDEMPOTENCY KEY USED FOR ANOTHER REQUEST")
+	errNoIdempotencyKey      = errors.New("NO IDEMPOTENCY KEY")
 	//go:embed migrations/*.sql
 	embedMigrations embed.FS
 )
 
-type StorageService struct {
-	db          *sql.DB
-	DatabaseDSN string
-}
+type (
+	StorageService struct {
+		pool        *pgxpool.Pool
+		db          *sql.DB // обёртка над pool для goose
+		log         logger.Lg
+		DatabaseDSN string
+		Passwords   sec.PasswordHasher
+		Metrics     IMetrics
+		dummyHash   string
+		migrated    *atomic.Bool // все встроенные миграции применены, см. PendingMigrations
+	}
+
+	// PoolConf — размер пула pgx и время жизни соединений. Нулевые значения
+	// оставляют заданное в DSN (pool_max_conns и т.п.) или умолчания pgx.
+	PoolConf struct {
+		MaxConns        int
+		MinConns        int
+		ConnMaxLifetime time.Duration
+		ConnMaxIdleTime time.Duration
+	}
+
+	// IMetrics — учёт баллов, проведённых по счетам.
+	IMetrics interface {
+		PointsIssued(points float64)
+		PointsWithdrawn(points float64)
+	}
+
+	// querier — общее у пула и транзакции pgx.
+	querier interface {
+		Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+		QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+		Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	}
+)
 
 const (
 	StatusNew        = "NEW"
@@ -39,733 +79,169 @@ const (
 	Invalid          = "INVALID"
 	Processed        = "PROCESSED"
 
+	PersonBlocked = "BLOCKED"
+
 	AcctSidePassive = "П"
 	AcctSideActive  = "А"
+	AcctSettlement  = "30102810000000000001"
+	AcctAccrual     = "70606810000000000001"
 	Base10          = 10
 	DefSliceLength  = 10
 	MaxDigitValue   = 9
+
+	idemResultOK       = "OK"
+	idemResultRedSaldo = "RED_SALDO"
 )
 
-func (s *StorageService) connect() error {
-	db, err := sql.Open("pgx", s.DatabaseDSN)
+func (s *StorageService) connect(p PoolConf) error {
+	cfg, err := pgxpool.ParseConfig(s.DatabaseDSN)
 
 	if err != nil {
-		return fmt.Errorf("CANT do sql.open: [%w]", err)
+		return fmt.Errorf("CAN'T PARSE DSN: [%w]", err)
 	}
 
-	if err := db.PingContext(context.Background()); err != nil {
-		return fmt.Errorf("CANT PING DB: [%w]", err)
+	if p.MaxConns > 0 {
+		cfg.MaxConns = int32(p.MaxConns) //nolint:gosec // границы проверяет conf.Validate
 	}
 
-	s.db = db
-
-	return nil
-}
-
-func (s *StorageService) splitToDigitRevers(num int) []int {
-	digitArray := []int{}
-	testNum := num
-
-	for testNum > 0 {
-		digitArray = append(digitArray, testNum%Base10)
-		testNum = (int)(testNum / Base10)
+	if p.MinConns > 0 {
+		cfg.MinConns = int32(min(p.MinConns, int(cfg.MaxConns))) //nolint:gosec // не больше MaxConns
 	}
 
-	return digitArray
-}
-
-func (s *StorageService) checkByLuhn(num int) error {
-	digitArray := s.splitToDigitRevers(num)
-
-	cK := 0
-
-	for k, v := range digitArray {
-		if (k+1)%2 == 0 {
-			testDigit := v * 2
-			if testDigit > MaxDigitValue {
-				smallDigitArray := s.splitToDigitRevers(testDigit)
-
-				testDigit = smallDigitArray[0] + smallDigitArray[1]
-			}
-			v = testDigit
-		}
-
-		cK += v
+	if p.ConnMaxLifetime > 0 {
+		cfg.MaxConnLifetime = p.ConnMaxLifetime
 	}
 
-	if cK%Base10 != 0 {
-		return ErrNoLuhnNumber
+	if p.ConnMaxIdleTime > 0 {
+		cfg.MaxConnIdleTime = p.ConnMaxIdleTime
 	}
 
-	return nil
-}
-
-func (s *StorageService) GetPesonByCredential(ctx context.Context, login, pass string) (models.Person, error) {
-	person := models.Person{}
-
-	row := s.db.QueryRowContext(ctx, "SELECT * FROM person WHERE login=$1 AND password=$2", login, pass)
-
-	var fullname, surname, name, status sql.NullString
-
-	err := row.Scan(&person.ID,
-		&person.Login,
-		&person.Pass,
-		&fullname,
-		&surname,
-		&name,
-		&status,
-		&person.Crdt,
-		&person.Updt)
-
-	person.Fullname = fullname.String
-	person.Surname = surname.String
-	person.Name = name.String
-	person.Status = status.String
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return person, ErrUserCredentialInvalid
-		}
-		return person, fmt.Errorf("CAN'T SEARCH PERSON BY CREDENTIAL [%w]", err)
-	}
-
-	return person, nil
-}
-
-func (s *StorageService) CreatePeson(ctx context.Context, p models.Person) (models.Person, error) {
-	var personID, acctID, acctSerial int
-
-	p.Crdt = time.Now()
-	p.Updt = p.Crdt
-
-	tx, err := s.db.Begin()
-
-	if err != nil {
-		return p, fmt.Errorf("CAN'T OPEN TRANSACT: [%v]", err)
-	}
-
-	err = tx.QueryRowContext(ctx, `INSERT INTO person (login,password,crdt,updt) VALUES($1,$2,$3,$4) RETURNING id`,
-		p.Login,
-		p.Pass,
-		p.Crdt,
-		p.Updt).Scan(&personID)
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 
 	if err != nil {
-		var perr *pgconn.PgError
-
-		if errors.As(err, &perr) && perr.Code == pgerrcode.UniqueViolation {
-			err := tx.Rollback()
-
-			if err != nil {
-				return p, fmt.Errorf("CANT ROLLBACK TRANSACTION")
-			}
-			return p, ErrUserExists
-		}
-
-		err := tx.Rollback()
-
-		if err != nil {
-			return p, fmt.Errorf("CANT ROLLBACK TRANSACTION")
-		}
-
-		return models.Person{}, fmt.Errorf("CAN'T CREATE PERSON [%w]", err)
+		return fmt.Errorf("CAN'T CREATE POOL: [%w]", err)
 	}
 
-	err = tx.QueryRowContext(ctx, `SELECT nextval('acctserial')`).Scan(&acctSerial)
-
-	if err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return p, fmt.Errorf("CANT ROLLBACK TRANSACTION")
-		}
-		return models.Person{}, fmt.Errorf("CAN'T ACCT SEQUENCE VALUE [%w]", err)
-	}
-	fmt.Println("SEQ:", acctSerial)
-	err = tx.QueryRowContext(ctx, `INSERT INTO acct (acct,person,sign,crdt,updt) VALUES($1,$2,'П',$3,$4) RETURNING id`,
-		`408178101`+fmt.Sprintf("%011d", acctSerial),
-		personID,
-		p.Crdt,
-		p.Updt).Scan(&acctID)
-
-	if err != nil {
-		var perr *pgconn.PgError
-
-		if errors.As(err, &perr) && perr.Code == pgerrcode.UniqueViolation {
-			err := tx.Rollback()
-			if err != nil {
-				return p, fmt.Errorf("CANT ROLLBACK TRANSACTION")
-			}
-			return p, ErrUserExists
-		}
-
-		err := tx.Rollback()
-
-		if err != nil {
-			return p, fmt.Errorf("CANT ROLLBACK TRANSACTION")
-		}
-
-		return models.Person{}, fmt.Errorf("CAN'T CREATE PERSON [%w]", err)
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		return fmt.Errorf("CANT PING DB: [%w]", err)
 	}
 
-	p.ID = uint(personID)
+	s.pool = pool
+	s.db = stdlib.OpenDBFromPool(pool)
 
-	err = tx.Commit()
-
-	if err != nil {
-		return p, fmt.Errorf("CANT COMMIT TRANSACTION")
-	}
-
-	return p, nil
+	return nil
 }
 
-func (s *StorageService) CreateOrder(ctx context.Context, p models.Person, order models.POrder) (models.POrder, error) {
-	var orderID int
-
-	err := s.checkByLuhn(order.Extnum)
-
-	if err != nil {
-		return order, fmt.Errorf("NO VALID LUHN [%w]", err)
-	}
-
-	order.Status = StatusNew
-
-	order.Pid = p.GetID()
-	order.Crdt = time.Now()
-	order.Updt = order.Crdt
-
-	err = s.db.QueryRowContext(ctx, `INSERT INTO porder (pid,extnum,status,crdt,updt) VALUES($1,$2,$3,$4) RETURNING id`,
-		order.Pid,
-		order.Extnum,
-		StatusNew,
-		order.Crdt,
-		order.Updt).Scan(&orderID)
-
-	if err != nil {
-		var perr *pgconn.PgError
-
-		if errors.As(err, &perr) && perr.Code != pgerrcode.UniqueViolation {
-			return order, err
-		}
-
-		dublicateOrder, err := s.GetOrder(ctx, order)
-
-		if err != nil {
-			return order, err
-		}
-
-		if dublicateOrder.GetPID() == order.Pid {
-			return order, ErrDublicateOrder
-		} else {
-			return order, ErrOrderExists
-		}
-	}
-
-	return order, nil
+// span открывает спан метода хранилища с видом SQL-операции.
+func (s *StorageService) span(ctx context.Context, name, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "StorageService."+name, append(attrs, tracing.DBOperation.String(op))...)
 }
 
-func (s *StorageService) GetOrder(ctx context.Context, order models.POrder) (models.POrder, error) {
-	var status sql.NullString
-
-	err := s.db.QueryRowContext(ctx, "SELECT id,pid,extnum,status,crdt,updt FROM porder WHERE extnum=$1", order.Extnum).
-		Scan(&order.ID,
-			&order.Pid,
-			&order.Extnum,
-			&status,
-			&order.Crdt,
-			&order.Updt)
-
-	order.Status = status.String
-
-	if err != nil {
-		return order, err
-	}
-
-	return order, nil
+func personAttr(id uint) attribute.KeyValue {
+	return tracing.PersonID.Int(int(id))
 }
 
-func (s *StorageService) GetOrders(ctx context.Context, p models.Person) ([]models.POrder, error) {
-	var (
-		accrual sql.NullInt64
-		status  sql.NullString
-	)
-
-	result := []models.POrder{}
-
-	rows, err := s.db.QueryContext(ctx, `SELECT id,pid,extnum,status,accrual,crdt,updt 
-										 FROM porder 
-										 WHERE pid=$1 
-										ORDER BY crdt DESC`, p.GetID())
-
-	if err != nil {
-		return result, fmt.Errorf("CAN'T GET ORDERS [%w]", err)
-	}
-
-	order := models.POrder{}
-
-	for rows.Next() {
-		err := rows.Scan(&order.ID,
-			&order.Pid,
-			&order.Extnum,
-			&status,
-			&accrual,
-			&order.Crdt,
-			&order.Updt)
-		order.Status = status.String
-
-		if err != nil {
-			return result, err
-		}
-
-		order.Accrual = int(accrual.Int64)
-
-		result = append(result, order)
-	}
-	return result, nil
+func orderAttr(extnum string) attribute.KeyValue {
+	return tracing.OrderNumber.String(extnum)
 }
 
-func (s *StorageService) GetPersonByID(ctx context.Context, id int) (models.Person, error) {
-	person := models.Person{}
-
-	row := s.db.QueryRowContext(ctx, "SELECT * FROM person WHERE id=$1", id)
-
-	var fullname, surname, name, status sql.NullString
-
-	err := row.Scan(&person.ID,
-		&person.Login,
-		&person.Pass,
-		&fullname,
-		&surname,
-		&name,
-		&status,
-		&person.Crdt,
-		&person.Updt)
-
-	person.Fullname = fullname.String
-	person.Surname = surname.String
-	person.Name = name.String
-	person.Status = status.String
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return person, err
-		}
-		return person, fmt.Errorf("CAN'T SEARCH PERSON BY ID [%w]", err)
-	}
-
-	return person, nil
+func acctAttr(acct string) attribute.KeyValue {
+	return attribute.String("acct", acct)
 }
 
-func (s *StorageService) getMoveByDb(ctx context.Context, acct string, opdate time.Time) ([]models.Opentry, error) { //nolint:stylecheck //It's debit neither DB
-	rows, err := s.db.QueryContext(ctx, `SELECT opentry.id,
-    											opentry.person,
-    											opentry.porder,
-    											opentry.status,
-    											opentry.opdate,
-    											opentry.acctdb,
-    											opentry.acctcr,
-    											opentry.sum1,
-    											opentry.sum2,    
-    											opentry.crdt,
-    											opentry.updt,
-												porder.extnum
-	FROM  opentry
-	LEFT JOIN porder ON porder.id=opentry.porder
-	WHERE acctdb=$1 
-	AND opdate>=$2`,
-		acct,
-		opdate)
+// Stats переводит статистику пула pgx в sql.DBStats, которую публикуют метрики.
+func (s *StorageService) Stats() sql.DBStats {
+	st := s.pool.Stat()
 
-	if err != nil {
-		return nil, fmt.Errorf("CAN'T READ OPENTRY BY DB: [%v]", err)
-	}
-
-	res := make([]models.Opentry, DefSliceLength)
-	var status sql.NullString
-	var extNum sql.NullInt32
-
-	for rows.Next() {
-		opentry := models.Opentry{}
-		err := rows.Scan(
-			&opentry.ID,
-			&opentry.Person,
-			&opentry.Porder,
-			&status,
-			&opentry.Opdate,
-			&opentry.Acctdb,
-			&opentry.Acctcr,
-			&opentry.Sum1,
-			&opentry.Sum2,
-			&opentry.Crdt,
-			&opentry.Updt,
-			&extNum)
-
-		opentry.Status = status.String
-		opentry.OrderExtNum = int(extNum.Int32)
-
-		if err != nil {
-			return nil, fmt.Errorf("CAN'T READ OPENTRY BY DB: [%v]", err)
-		}
-
-		res = append(res, opentry)
-	}
-
-	return res, nil
-}
-
-func (s *StorageService) getMoveByCr(ctx context.Context, acct string, opdate time.Time) ([]models.Opentry, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id,
-    											person,
-    											porder,
-    											status,
-    											opdate,
-    											acctdb,
-    											acctcr,
-    											sum1,
-    											sum2,    
-    											crdt,
-    											updt
-	FROM  opentry
-	WHERE acctcr=$1 
-	AND opdate>=$2`,
-		acct,
-		opdate)
-
-	if err != nil {
-		return nil, fmt.Errorf("CAN'T READ OPENTRY BY CR: [%v]", err)
-	}
-
-	res := make([]models.Opentry, DefSliceLength)
-	var status sql.NullString
-
-	for rows.Next() {
-		opentry := models.Opentry{}
-		err := rows.Scan(
-			&opentry.ID,
-			&opentry.Person,
-			&opentry.Porder,
-			&status,
-			&opentry.Opdate,
-			&opentry.Acctdb,
-			&opentry.Acctcr,
-			&opentry.Sum1,
-			&opentry.Sum2,
-			&opentry.Crdt,
-			&opentry.Updt)
-
-		opentry.Status = status.String
-
-		if err != nil {
-			return nil, fmt.Errorf("CAN'T READ OPENTRY BY CR: [%v]", err)
-		}
-
-		res = append(res, opentry)
+	return sql.DBStats{
+		MaxOpenConnections: int(st.MaxConns()),
+		OpenConnections:    int(st.TotalConns()),
+		InUse:              int(st.AcquiredConns()),
+		Idle:               int(st.IdleConns()),
+		WaitCount:          st.EmptyAcquireCount(),
+		WaitDuration:       st.AcquireDuration(),
 	}
-
-	return res, nil
 }
 
-func (s *StorageService) getLastFixBalance(ctx context.Context, acct models.Acct) (models.AcctBal, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id,
-											 person,
-											 opdate,
-											 acct,
-											 balance,
-											 db,
-											 cr,
-											 crdt,
-											 updt
-	                                  FROM acctbal 
-									  WHERE acct=$1 AND opdate>$2
-									  ORDER BY opdate DESC LIMIT 1`,
-		acct.Acct,
-		acct.Crdt)
-
-	acctbal := models.AcctBal{}
-
-	err := row.Scan(
-		&acctbal.ID,
-		&acctbal.Person,
-		&acctbal.Opdate,
-		&acctbal.Acct,
-		&acctbal.Balance,
-		&acctbal.Db,
-		&acctbal.Cr,
-		&acctbal.Crdt,
-		&acctbal.Updt)
-
-	if err != nil {
-		if err != sql.ErrNoRows {
-			return models.AcctBal{}, fmt.Errorf("CAN'T READ ACCTBAL INFO [%v]", err)
-		}
-
-		return models.AcctBal{}, ErrNoFixedBalance
-	}
-
-	return acctbal, nil
-}
-func (s *StorageService) calcBalanceByAcct(ctx context.Context, acct models.Acct) (int, error) {
-	balance := 0
-
-	acctbal, err := s.getLastFixBalance(ctx, acct)
-
-	if err != nil {
-		if !errors.Is(err, ErrNoFixedBalance) {
-			return 0, fmt.Errorf("CAN'T READ ACCTBAL INFO [%v]", err)
-		}
-	} else {
-		balance += acctbal.Balance
-	}
-
-	rows, err := s.getMoveByDb(ctx, acct.Acct, acctbal.Opdate)
-
-	if err != nil {
-		return 0, fmt.Errorf("CAN'T READ OPENTRY BY CR INFO [%v]", err)
-	}
-
-	for _, opentry := range rows {
-		if acct.Sign == AcctSideActive {
-			balance += opentry.Sum1
-		} else if acct.Sign == AcctSidePassive {
-			balance -= opentry.Sum1
-		}
+// Close закрывает пул соединений с БД.
+func (s *StorageService) Close() error {
+	if s.pool == nil {
+		return nil
 	}
 
-	rows, err = s.getMoveByCr(ctx, acct.Acct, acctbal.Opdate)
+	err := s.db.Close()
+	s.pool.Close()
 
 	if err != nil {
-		return 0, fmt.Errorf("CAN'T READ OPENTRY BY DB INFO [%v]", err)
-	}
-
-	for _, opentry := range rows {
-		if acct.Sign == AcctSideActive {
-			balance -= opentry.Sum1
-		} else if acct.Sign == AcctSidePassive {
-			balance += opentry.Sum1
-		}
-	}
-
-	return balance, nil
-}
-
-func (s *StorageService) getPersonAccts(ctx context.Context, p models.Person) ([]models.Acct, error) {
-
-	rows, err := s.db.QueryContext(ctx, "SELECT id,acct,person,sign,status,crdt,updt FROM acct WHERE person=$1", p.GetID())
-
-	if err != nil {
-		return nil, fmt.Errorf("CAN'T FIND PERSON acct [%v]", err)
-	}
-
-	res := make([]models.Acct, DefSliceLength)
-
-	for rows.Next() {
-		var status, sign sql.NullString
-		acct := models.Acct{}
-		err := rows.Scan(&acct.ID, &acct.Acct, &acct.Person, &sign, &status, &acct.Crdt, &acct.Updt)
-		acct.Status = status.String
-		acct.Sign = sign.String
-
-		if err != nil {
-			return nil, fmt.Errorf("CAN'T CREATE ACCT STRUCT [%v]", err)
-		}
-
-		acct.Status = status.String
-		acct.Sign = sign.String
-		res = append(res, acct)
+		return fmt.Errorf("CAN'T CLOSE DB [%w]", err)
 	}
 
-	return res, nil
+	return nil
 }
 
-func (s *StorageService) GetBalance(ctx context.Context, p models.Person) (int, error) {
-	b := 0
-
-	accts, err := s.getPersonAccts(ctx, p)
-
-	if err != nil {
-		return 0, fmt.Errorf("CAN'T FIND PERSON ACCT [%v]", err)
-	}
-
-	for _, acct := range accts {
-		if b0, err := s.calcBalanceByAcct(ctx, acct); err == nil {
-			b += b0
-		}
+// CheckLuhn проверяет строку цифр алгоритмом Луна.
+func CheckLuhn(num string) error {
+	if num == "" {
+		return ErrNoLuhnNumber
 	}
-	return b, nil
-}
-
-func (s *StorageService) Getwithdrawn(ctx context.Context, p models.Person) (int, error) {
-	b := 0
 
-	accts, err := s.getPersonAccts(ctx, p)
-
-	if err != nil {
-		return 0, fmt.Errorf("CAN'T GET PERSON ACCTS")
-	}
+	cK := 0
 
-	for _, acct := range accts {
-		fixedBalance, err := s.getLastFixBalance(ctx, acct)
+	for k := range len(num) {
+		v := int(num[len(num)-1-k] - '0')
 
-		if err != nil {
-			if !errors.Is(err, ErrNoFixedBalance) {
-				return 0, fmt.Errorf("CAN'T CHECK LAST FIXED BALANCE: [%v]", err)
-			}
+		if v < 0 || v > MaxDigitValue {
+			return ErrNoLuhnNumber
 		}
 
-		if acct.Sign == AcctSidePassive {
-			b += fixedBalance.Db
-			rows, err := s.getMoveByDb(ctx, acct.Acct, fixedBalance.Opdate)
-
-			if err != nil {
-				return 0, fmt.Errorf("CAN'T GET ACCT MOBY BY DB: [%v]", err)
-			}
-			for _, opentry := range rows {
-				b += opentry.Sum1
-			}
-		} else if acct.Sign == AcctSideActive {
-			b += fixedBalance.Cr
-
-			rows, err := s.getMoveByCr(ctx, acct.Acct, fixedBalance.Opdate)
-
-			if err != nil {
-				return 0, fmt.Errorf("CAN'T GET ACCT MOBY BY DB: [%v]", err)
-			}
-			for _, opentry := range rows {
-				b += opentry.Sum1
+		if k%2 == 1 {
+			if v *= 2; v > MaxDigitValue {
+				v -= MaxDigitValue
 			}
 		}
-	}
-
-	return b, nil
-}
-
-func (s *StorageService) CreateWithdrawn(ctx context.Context, p models.Person, o models.POrder, sum int) (models.Opentry, error) {
-	balance, err := s.GetBalance(ctx, p)
-
-	if err != nil {
-		return models.Opentry{}, fmt.Errorf("CAN GET BALANCE: [%v]", err)
-	}
-
-	if sum == 0 {
-		return models.Opentry{}, fmt.Errorf("ZERO SUM TO WITHDRAW")
-	}
-
-	if sum > balance {
-		return models.Opentry{}, ErrRedSaldo
-	}
-
-	accts, err := s.getPersonAccts(ctx, p)
-
-	if err != nil {
-		return models.Opentry{}, fmt.Errorf("CAN'T GET PERSON ACCT: [%v]", err)
-	}
-
-	if len(accts) == 0 {
-		return models.Opentry{}, fmt.Errorf("NO ACTIVE ACCT FOR PERSON")
-	}
-
-	acct := accts[0]
-
-	opentry := models.Opentry{
-		Person:      p.GetID(),
-		Porder:      o.ID,
-		Status:      StatusNew,
-		OrderExtNum: o.Extnum,
-		Opdate:      time.Now(),
-		Sum1:        sum,
-		Crdt:        time.Now(),
-		Updt:        time.Now(),
-	}
 
-	if acct.Sign == AcctSidePassive {
-		opentry.Acctdb = acct.Acct
-		opentry.Acctcr = "30102810000000000001"
-	} else if acct.Sign == AcctSideActive {
-		opentry.Acctcr = acct.Acct
-		opentry.Acctdb = "30102810000000000001"
-	}
-
-	var opentryID uint
-	err = s.db.QueryRowContext(ctx, `INSERT INTO opentry (person,porder,orderextnum,opdate,acctdb,acctcr,sum1,crdt,updt) 
-	                                 VALUES($1,$2,$3,$4) RETURNING id`,
-		opentry.Person,
-		opentry.Porder,
-		opentry.OrderExtNum,
-		opentry.Status,
-		opentry.Opdate,
-		opentry.Acctdb,
-		opentry.Acctcr,
-		opentry.Sum1,
-		opentry.Crdt,
-		opentry.Updt).
-		Scan(&opentryID)
-
-	if err != nil {
-		return models.Opentry{}, fmt.Errorf("CAN'T INSERT OPENTRY")
-	}
-
-	opentry.ID = opentryID
-
-	return opentry, nil
-}
-func (s *StorageService) GetWithdrawals(ctx context.Context, p models.Person) ([]models.Opentry, error) {
-	accts, err := s.getPersonAccts(ctx, p)
-
-	if err != nil {
-		return nil, fmt.Errorf("CAN'T FIND PERSON ACCT [%v]", err)
+		cK += v
 	}
 
-	rows := make([]models.Opentry, DefSliceLength)
-
-	for _, acct := range accts {
-		if acct.Sign == AcctSidePassive {
-			r, e := s.getMoveByDb(ctx, acct.Acct, acct.Crdt)
-
-			if e != nil {
-				return nil, fmt.Errorf("ACCT IS PASSIVE AND CAN'T GET MOVE BY DB [%v]", err)
-			}
-
-			rows = append(rows, r...)
-		} else if acct.Sign == AcctSideActive {
-			r, e := s.getMoveByCr(ctx, acct.Acct, acct.Crdt)
-
-			if e != nil {
-				return nil, fmt.Errorf("ACCT IS ACTIVE AND CAN'T GET MOVE BY DB [%v]", err)
-			}
-
-			rows = append(rows, r...)
-		}
+	if cK%Base10 != 0 {
+		return ErrNoLuhnNumber
 	}
 
-	return rows, nil
+	return nil
 }
 
-func (s *StorageService) RunMigrations() error {
-	goose.SetBaseFS(embedMigrations)
-	err := goose.SetDialect("postgres")
+func (s *StorageService) Ping(ctx context.Context) error {
+	ctx, span := s.span(ctx, "Ping", "PING")
+	defer span.End()
 
-	if err != nil {
-		return fmt.Errorf("CAN'T SELECT GOOSE DIALECT: [%v]", err)
+	if err := s.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("CANT PING DB: [%w]", err)
 	}
 
-	if err := goose.Up(s.db, "migrations"); err != nil {
-		return fmt.Errorf("CAN'T UP GOOSE MIGRATION: [%v]", err)
-	}
 	return nil
 }
 
-func NewStorageService(log logger.Lg, dsn string) (StorageService, error) {
+// NewStorageService подключается к БД. Миграции не применяются: для этого есть MigrateUp.
+func NewStorageService(log logger.Lg, dsn string, pool PoolConf) (StorageService, error) {
 	s := StorageService{
 		DatabaseDSN: dsn,
+		log:         log,
+		Passwords:   sec.NewPasswordHasher(),
+		Metrics:     metrics.Nop{},
+		migrated:    &atomic.Bool{},
 	}
 
-	if err := s.connect(); err != nil {
-		return s, fmt.Errorf("CAN'T CONNECT TO DB [%w]", err)
+	dummyHash, err := s.Passwords.Hash("")
+
+	if err != nil {
+		return s, fmt.Errorf("CAN'T PREPARE PASSWORD HASHER [%w]", err)
 	}
 
-	err := s.RunMigrations()
+	s.dummyHash = dummyHash
 
-	if err != nil {
-		log.Panicln(err)
+	if err := s.connect(pool); err != nil {
+		return s, fmt.Errorf("CAN'T CONNECT TO DB [%w]", err)
 	}
 
 	return s, nil
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/logger"
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/DmitryM7/yapr56.git/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDB подключается к PostgreSQL из TEST_DATABASE_URI и применяет миграции.
+// Без переменной тест пропускается.
+func newDB(t *testing.T) *StorageService {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URI")
+
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI IS NOT SET")
+	}
+
+	s, err := NewStorageService(logger.NewLg(), dsn, PoolConf{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	_, err = s.MigrateUp(context.Background())
+	require.NoError(t, err)
+
+	return &s
+}
+
+// luhnNumber дописывает к prefix контрольную цифру Луна.
+func luhnNumber(prefix string) string {
+	for d := range Base10 {
+		n := prefix + strconv.Itoa(d)
+
+		if CheckLuhn(n) == nil {
+			return n
+		}
+	}
+
+	return ""
+}
+
+func TestStorageService_RecalcBalances(t *testing.T) {
+	ctx := context.Background()
+	s := newDB(t)
+
+	uniq := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	p, err := s.CreatePeson(ctx, models.Person{Login: "recalc" + uniq, Pass: "secret12"})
+	require.NoError(t, err)
+
+	order, err := s.CreateOrder(ctx, p, models.POrder{Extnum: luhnNumber("1" + uniq)})
+	require.NoError(t, err)
+
+	order.Status = Processed
+	order.Accrual = money.Money(50050)
+	require.NoError(t, s.UpdateOrder(ctx, order))
+
+	// счета и начисление переносятся на позавчера, чтобы их покрыло сальдо на вчера
+	past := time.Now().AddDate(0, 0, -2)
+
+	_, err = s.pool.Exec(ctx, "UPDATE acct SET crdt=$1 WHERE person=$2", past, p.ID)
+	require.NoError(t, err)
+
+	_, err = s.pool.Exec(ctx, `UPDATE opentry SET opdate=$1
+							   WHERE acctdb IN (SELECT acct FROM acct WHERE person=$2)
+							      OR acctcr IN (SELECT acct FROM acct WHERE person=$2)`, past, p.ID)
+	require.NoError(t, err)
+
+	_, err = s.RecalcBalances(ctx, time.Now().AddDate(0, 0, 1))
+	require.ErrorIs(t, err, ErrRecalcInFuture)
+
+	fixes, err := s.RecalcBalances(ctx, time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+
+	var fix *BalanceFix
+
+	for i := range fixes {
+		if fixes[i].Person == int(p.ID) {
+			fix = &fixes[i]
+		}
+	}
+
+	require.NotNil(t, fix, fmt.Sprintf("acct of person %d is recalculated", p.ID))
+	assert.Equal(t, money.Money(50050), fix.Before)
+	assert.Equal(t, money.Money(50050), fix.After)
+
+	_, err = s.CreateWithdrawn(ctx, p, models.POrder{Extnum: luhnNumber("2" + uniq)}, money.Money(10000), "")
+	require.NoError(t, err)
+
+	balance, err := s.GetBalance(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, money.Money(40050), balance, "withdrawal after the fixed date is counted")
+}
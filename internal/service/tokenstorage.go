@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DmitryM7/yapr56.git/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("REFRESH TOKEN INVALID")
+	ErrRefreshTokenReused  = errors.New("REFRESH TOKEN REUSED")
+)
+
+func (s *StorageService) SaveRefreshToken(ctx context.Context, t models.RefreshToken) (models.RefreshToken, error) {
+	ctx, span := s.span(ctx, "SaveRefreshToken", "INSERT", personAttr(t.Person))
+	defer span.End()
+
+	t.Crdt = time.Now()
+	t.Updt = t.Crdt
+
+	err := s.pool.QueryRow(ctx, `INSERT INTO refresh_token (person,family,hash,expires,crdt,updt)
+									  VALUES($1,$2,$3,$4,$5,$6) RETURNING id`,
+		t.Person,
+		t.Family,
+		t.Hash,
+		t.Expires,
+		t.Crdt,
+		t.Updt).Scan(&t.ID)
+
+	if err != nil {
+		return t, fmt.Errorf("CAN'T SAVE REFRESH TOKEN [%w]", err)
+	}
+
+	return t, nil
+}
+
+// RotateRefreshToken гасит refresh-токен с хешем oldHash и сохраняет вместо него next
+// из того же семейства. Повторное предъявление уже использованного токена означает его
+// утечку: всё семейство отзывается и возвращается ErrRefreshTokenReused.
+func (s *StorageService) RotateRefreshToken(ctx context.Context, oldHash string, next models.RefreshToken) (models.RefreshToken, error) {
+	ctx, span := s.span(ctx, "RotateRefreshToken", "UPDATE")
+	defer span.End()
+
+	var reused bool
+
+	// отзыв семейства должен сохраниться, поэтому повтор сообщается через reused,
+	// а fn завершается без ошибки
+	err := s.WithTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		reused = false
+
+		old := models.RefreshToken{}
+
+		err := tx.QueryRow(ctx, `SELECT id,person,family,hash,expires,used,revoked
+								   FROM refresh_token WHERE hash=$1 FOR UPDATE`, oldHash).
+			Scan(&old.ID, &old.Person, &old.Family, &old.Hash, &old.Expires, &old.Used, &old.Revoked)
+
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrRefreshTokenInvalid
+			}
+			return fmt.Errorf("CAN'T READ REFRESH TOKEN [%w]", err)
+		}
+
+		if old.Used && !old.Revoked {
+			reused = true
+			return s.revokeRefreshFamily(ctx, tx, old.Family)
+		}
+
+		if !old.IsActive() {
+			return ErrRefreshTokenInvalid
+		}
+
+		now := time.Now()
+
+		if _, err := tx.Exec(ctx, `UPDATE refresh_token SET used=TRUE,updt=$1 WHERE id=$2`, now, old.ID); err != nil {
+			return fmt.Errorf("CAN'T MARK REFRESH TOKEN USED [%w]", err)
+		}
+
+		next.Person = old.Person
+		next.Family = old.Family
+		next.Crdt = now
+		next.Updt = now
+
+		err = tx.QueryRow(ctx, `INSERT INTO refresh_token (person,family,hash,expires,crdt,updt)
+								   VALUES($1,$2,$3,$4,$5,$6) RETURNING id`,
+			next.Person,
+			next.Family,
+			next.Hash,
+			next.Expires,
+			next.Crdt,
+			next.Updt).Scan(&next.ID)
+
+		if err != nil {
+			return fmt.Errorf("CAN'T SAVE REFRESH TOKEN [%w]", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return next, err
+	}
+
+	if reused {
+		return next, ErrRefreshTokenReused
+	}
+
+	return next, nil
+}
+
+// RevokeRefreshToken отзывает всё семейство, к которому относится токен с хешем hash.
+func (s *StorageService) RevokeRefreshToken(ctx context.Context, hash string) error {
+	ctx, span := s.span(ctx, "RevokeRefreshToken", "UPDATE")
+	defer span.End()
+
+	_, err := s.pool.Exec(ctx, `UPDATE refresh_token SET revoked=TRUE,updt=$1
+									 WHERE family=(SELECT family FROM refresh_token WHERE hash=$2)`,
+		time.Now(),
+		hash)
+
+	if err != nil {
+		return fmt.Errorf("CAN'T REVOKE REFRESH TOKEN [%w]", err)
+	}
+
+	return nil
+}
+
+func (s *StorageService) revokeRefreshFamily(ctx context.Context, q querier, family string) error {
+	ctx, span := s.span(ctx, "revokeRefreshFamily", "UPDATE")
+	defer span.End()
+
+	_, err := q.Exec(ctx, `UPDATE refresh_token SET revoked=TRUE,updt=$1 WHERE family=$2`, time.Now(), family)
+
+	if err != nil {
+		return fmt.Errorf("CAN'T REVOKE REFRESH TOKEN FAMILY [%w]", err)
+	}
+
+	return nil
+}
+
+// RevokeToken заносит jti access-токена в список отозванных до окончания его срока.
+// Заодно удаляются записи, срок которых уже истёк.
+func (s *StorageService) RevokeToken(ctx context.Context, jti string, expires time.Time) error {
+	ctx, span := s.span(ctx, "RevokeToken", "INSERT")
+	defer span.End()
+
+	return s.WithTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `INSERT INTO revoked_token (jti,expires) VALUES($1,$2) ON CONFLICT (jti) DO NOTHING`,
+			jti,
+			expires)
+
+		if err != nil {
+			return fmt.Errorf("CAN'T REVOKE TOKEN [%w]", err)
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM revoked_token WHERE expires<$1`, time.Now()); err != nil {
+			return fmt.Errorf("CAN'T PURGE REVOKED TOKENS [%w]", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *StorageService) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, span := s.span(ctx, "IsTokenRevoked", "SELECT")
+	defer span.End()
+
+	var revoked bool
+
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM revoked_token WHERE jti=$1)`, jti).Scan(&revoked)
+
+	if err != nil {
+		return false, fmt.Errorf("CAN'T CHECK REVOKED TOKEN [%w]", err)
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	txMaxAttempts = 3
+	txRetryDelay  = 20 * time.Millisecond
+)
+
+// WithTx выполняет fn в транзакции с параметрами opts: фиксирует её, если fn вернула
+// nil, и откатывает иначе. Ошибка fn возвращается как есть, чтобы вызывающий мог
+// сравнить её с ErrRedSaldo и т.п. При взаимоблокировке, а на уровнях RepeatableRead
+// и Serializable и при конфликте сериализации, транзакция повторяется целиком,
+// поэтому fn не должна менять ничего, кроме БД и своих локальных результатов.
+func (s *StorageService) WithTx(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := pgx.BeginTxFunc(ctx, s.pool, opts, fn)
+
+		if err == nil || attempt == txMaxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		s.log.Debugln("RETRY TRANSACTION, ATTEMPT", attempt, err)
+
+		timer := time.NewTimer(time.Duration(attempt) * txRetryDelay)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("TRANSACTION RETRY INTERRUPTED [%w]", errors.Join(err, ctx.Err()))
+		}
+	}
+}
+
+// isRetryable — ошибки, после которых транзакцию достаточно повторить.
+func isRetryable(err error) bool {
+	var perr *pgconn.PgError
+
+	return errors.As(err, &perr) &&
+		(perr.Code == pgerrcode.SerializationFailure || perr.Code == pgerrcode.DeadlockDetected)
+}
+
+func isUniqueViolation(err error) bool {
+	var perr *pgconn.PgError
+
+	return errors.As(err, &perr) && perr.Code == pgerrcode.UniqueViolation
+}
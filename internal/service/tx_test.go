@@ -0,0 +1,22 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	wrap := func(code string) error {
+		return fmt.Errorf("CAN'T CALC BALANCE [%w]", &pgconn.PgError{Code: code})
+	}
+
+	assert.True(t, isRetryable(wrap(pgerrcode.SerializationFailure)))
+	assert.True(t, isRetryable(wrap(pgerrcode.DeadlockDetected)))
+	assert.False(t, isRetryable(wrap(pgerrcode.UniqueViolation)))
+	assert.False(t, isRetryable(errors.New("CAN'T CONNECT")))
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	ServiceName = "gophermart"
+	tracerName  = "github.com/DmitryM7/yapr56.git"
+)
+
+var ErrUnknownExporter = errors.New("UNKNOWN TRACE EXPORTER")
+
+// Атрибуты, общие для HTTP, хранилища и клиента начислений.
+var (
+	PersonID    = attribute.Key("person.id")
+	OrderNumber = attribute.Key("order.number")
+	DBOperation = attribute.Key("db.operation")
+	DBTable     = attribute.Key("db.sql.table")
+)
+
+type Conf struct {
+	Exporter string
+	Endpoint string
+}
+
+// Setup настраивает W3C-распространение контекста и экспорт спанов. Без экспортёра
+// глобальный провайдер остаётся no-op. Возвращаемая функция сбрасывает буферы.
+func Setup(ctx context.Context, conf Conf) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch strings.ToLower(conf.Exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlpOptions(conf.Endpoint)...)
+	default:
+		return nil, fmt.Errorf("%w [%s]", ErrUnknownExporter, conf.Exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T CREATE TRACE EXPORTER [%w]", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+
+	if err != nil {
+		return nil, fmt.Errorf("CAN'T CREATE TRACE RESOURCE [%w]", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// otlpOptions принимает как host:port, так и полный URL. Без адреса действуют
+// стандартные переменные OTEL_EXPORTER_OTLP_*.
+func otlpOptions(endpoint string) []otlptracehttp.Option {
+	switch {
+	case endpoint == "":
+		return nil
+	case strings.Contains(endpoint, "://"):
+		return []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}
+	default:
+		return []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure()}
+	}
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail отмечает спан ошибкой и возвращает её без изменений.
+func Fail(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}